@@ -0,0 +1,192 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// annotationPrefix marks a comment on a metric declaration as an annotation, e.g. //metric:range=0..1
+const annotationPrefix = "//metric:"
+
+// annotations validate the value of each supported annotation, keyed by the annotation name
+var annotations = map[string]func(string) error{
+	"range": func(value string) error {
+		_, _, err := parseRange(value)
+		return err
+	},
+	// internal metrics are left out of the document unless -include-internal is set
+	"internal": noValue,
+	// exemplars marks a metric that is observed with exemplars, e.g. the trace that an observation belongs to
+	"exemplars": noValue,
+	// created-timestamp marks a counter, histogram, or summary whose creation time is exposed as a _created series, e.g.
+	// when scraped with OpenMetrics created samples enabled. Gauges don't expose the series so it's ignored on them.
+	"created-timestamp": noValue,
+	// feature-gate names the feature gate that must be enabled for the metric to be emitted
+	"feature-gate": requiredValue,
+	// staleness is how often a periodically updated metric is expected to be updated, e.g. 30s
+	"staleness": func(value string) error {
+		_, err := parseStaleness(value)
+		return err
+	},
+	// downsample recommends querying a high-frequency metric through its recording rule rather than the raw series
+	"downsample": func(value string) error {
+		if value != "recommended" {
+			return fmt.Errorf("downsample %q must be recommended", value)
+		}
+		return nil
+	},
+	// typical-rate is the magnitude that a counter is usually incremented at, as guidance for setting alert thresholds
+	"typical-rate": func(value string) error {
+		if _, ok := typicalRates[value]; !ok {
+			return fmt.Errorf("typical rate %q must be one of %v", value, slices.Sorted(maps.Keys(typicalRates)))
+		}
+		return nil
+	},
+}
+
+// typicalRates describe the magnitude of each value of the typical-rate annotation
+var typicalRates = map[string]string{
+	"low":    "single-digit per minute",
+	"medium": "single-digit per second",
+	"high":   "hundreds per second or more",
+}
+
+// noValue validates an annotation that acts as a marker and so doesn't take a value
+func noValue(value string) error {
+	if value != "" {
+		return fmt.Errorf("annotation doesn't take a value, got %q", value)
+	}
+	return nil
+}
+
+// requiredValue validates an annotation that must be given a value
+func requiredValue(value string) error {
+	if value == "" {
+		return fmt.Errorf("annotation requires a value")
+	}
+	return nil
+}
+
+// createdSeries is the created-timestamp series of an annotated counter, histogram or summary
+func (i metricInfo) createdSeries() string {
+	if _, ok := i.Annotations["created-timestamp"]; !ok || i.MetricType == metricTypeGauge {
+		return ""
+	}
+	return fmt.Sprintf("%s_created", strings.TrimSuffix(i.qualifiedName(), "_total"))
+}
+
+func (i metricInfo) internal() bool {
+	_, ok := i.Annotations["internal"]
+	return ok
+}
+
+// parseAnnotations returns the //metric: annotations of a declaration, keyed by their names
+func parseAnnotations(doc *ast.CommentGroup) map[string]string {
+	if doc == nil {
+		return nil
+	}
+	parsed := map[string]string{}
+	for _, comment := range doc.List {
+		annotation, ok := strings.CutPrefix(comment.Text, annotationPrefix)
+		if !ok {
+			continue
+		}
+		name, value, _ := strings.Cut(strings.TrimSpace(annotation), "=")
+		parsed[name] = value
+	}
+	if len(parsed) == 0 {
+		return nil
+	}
+	return parsed
+}
+
+// parseRange parses the bounds of a range annotation, e.g. 0..1
+func parseRange(value string) (float64, float64, error) {
+	lower, upper, ok := strings.Cut(value, "..")
+	if !ok {
+		return 0, 0, fmt.Errorf("range %q must be of the form min..max", value)
+	}
+	min, err := strconv.ParseFloat(lower, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("range %q has an invalid lower bound, %w", value, err)
+	}
+	max, err := strconv.ParseFloat(upper, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("range %q has an invalid upper bound, %w", value, err)
+	}
+	if min > max {
+		return 0, 0, fmt.Errorf("range %q has a lower bound greater than its upper bound", value)
+	}
+	return min, max, nil
+}
+
+// parseStaleness parses the update interval of a staleness annotation, which must be a positive duration
+func parseStaleness(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("staleness %q must be a duration such as 30s, %w", value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("staleness %q must be positive", value)
+	}
+	return d, nil
+}
+
+// lintFeatureGates flags feature-gate annotations that name a feature gate unknown to the config
+func lintFeatureGates(cfg *config, metrics []metricInfo) []warning {
+	var warnings []warning
+	for _, m := range metrics {
+		if gate := m.Annotations["feature-gate"]; gate != "" && !slices.Contains(cfg.FeatureGates, gate) {
+			warnings = append(warnings, warning{
+				rule:     "feature-gate",
+				metric:   m.qualifiedName(),
+				position: m.Position,
+				message:  fmt.Sprintf("%s requires the feature gate %s, which isn't one of the known feature gates %v", m.qualifiedName(), gate, cfg.FeatureGates),
+			})
+		}
+	}
+	return warnings
+}
+
+// lintAnnotations flags annotations that aren't supported or whose values are malformed
+func lintAnnotations(metrics []metricInfo) []warning {
+	var warnings []warning
+	for _, m := range metrics {
+		names := make([]string, 0, len(m.Annotations))
+		for name := range m.Annotations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			validate, ok := annotations[name]
+			if !ok {
+				warnings = append(warnings, warning{rule: "annotation", metric: m.qualifiedName(), position: m.Position, message: fmt.Sprintf("%s has an unknown annotation %q", m.qualifiedName(), name)})
+				continue
+			}
+			if err := validate(m.Annotations[name]); err != nil {
+				warnings = append(warnings, warning{rule: "annotation", metric: m.qualifiedName(), position: m.Position, message: fmt.Sprintf("%s has a malformed %s annotation, %s", m.qualifiedName(), name, err)})
+			}
+		}
+	}
+	return warnings
+}
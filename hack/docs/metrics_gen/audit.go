@@ -0,0 +1,57 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/samber/lo"
+)
+
+// writeAudit writes the unresolved expressions, most frequent first
+func writeAudit(w io.Writer, metrics []metricInfo) {
+	type occurrence struct {
+		metric string
+		unresolvedExpr
+	}
+	var occurrences []occurrence
+	for _, m := range metrics {
+		for _, e := range m.UnresolvedExprs {
+			occurrences = append(occurrences, occurrence{metric: m.qualifiedName(), unresolvedExpr: e})
+		}
+	}
+	if len(occurrences) == 0 {
+		fmt.Fprintln(w, "every expression was resolved")
+		return
+	}
+	slices.SortStableFunc(occurrences, func(a, b occurrence) int {
+		return cmp.Or(cmp.Compare(a.Position.Filename, b.Position.Filename), cmp.Compare(a.Position.Offset, b.Position.Offset))
+	})
+	groups := lo.GroupBy(occurrences, func(o occurrence) string { return o.Expr })
+	exprs := lo.Keys(groups)
+	slices.SortFunc(exprs, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(groups[b]), len(groups[a])), cmp.Compare(a, b))
+	})
+	for _, expr := range exprs {
+		group := groups[expr]
+		fmt.Fprintf(w, "%s (%d %s%s)\n", expr, len(group), lo.Ternary(len(group) == 1, "occurrence", "occurrences"), lo.Ternary(group[0].Unsupported, ", unsupported", ""))
+		for _, o := range group {
+			fmt.Fprintf(w, "  %s %s of %s\n", o.Position, o.Field, o.metric)
+		}
+	}
+}
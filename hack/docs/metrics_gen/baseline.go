@@ -0,0 +1,122 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+
+	"github.com/awslabs/operatorpkg/serrors"
+	"github.com/samber/lo"
+)
+
+type baselineFormat string
+
+const (
+	baselineFormatJSON     baselineFormat = "json"
+	baselineFormatMarkdown baselineFormat = "markdown"
+)
+
+var baselineFormats = []baselineFormat{baselineFormatJSON, baselineFormatMarkdown}
+
+var (
+	markdownMetricHeading  = regexp.MustCompile("^#{3,4} (?:~~)?`([^`]+)`(?:~~)?$")
+	markdownStabilityLevel = regexp.MustCompile(`^- Stability Level: (.+)$`)
+)
+
+// loadBaseline reads the metrics documented by a previously generated document
+func loadBaseline(path string, f baselineFormat) ([]documentedMetric, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, serrors.Wrap(fmt.Errorf("reading baseline, %w", err), "path", path)
+	}
+	if f == baselineFormatMarkdown {
+		return parseMarkdownBaseline(data), nil
+	}
+	var baseline []documentedMetric
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, serrors.Wrap(fmt.Errorf("parsing baseline, %w", err), "path", path)
+	}
+	return baseline, nil
+}
+
+// parseMarkdownBaseline reverses the markdown renderer, keyed on the heading of each metric and its stability level
+func parseMarkdownBaseline(data []byte) []documentedMetric {
+	var baseline []documentedMetric
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if match := markdownMetricHeading.FindStringSubmatch(scanner.Text()); match != nil {
+			baseline = append(baseline, documentedMetric{Name: match[1]})
+		} else if match := markdownStabilityLevel.FindStringSubmatch(scanner.Text()); match != nil && len(baseline) > 0 {
+			baseline[len(baseline)-1].StabilityLevel = match[1]
+		}
+	}
+	return baseline
+}
+
+// diffBaseline compares the documented metrics with a baseline by their names
+func diffBaseline(baseline, documented []documentedMetric) []metricChange {
+	baseByName := lo.KeyBy(baseline, func(m documentedMetric) string { return m.Name })
+	byName := lo.KeyBy(documented, func(m documentedMetric) string { return m.Name })
+	var changes []metricChange
+	for name, m := range byName {
+		b, ok := baseByName[name]
+		if !ok {
+			changes = append(changes, metricChange{kind: changeAdded, name: name})
+			continue
+		}
+		var fields []string
+		if b.Type != "" && b.Type != m.Type {
+			fields = append(fields, "type")
+		}
+		if b.Help != "" && b.Help != m.Help {
+			fields = append(fields, "help")
+		}
+		if b.Labels != nil && !slices.Equal(slices.Sorted(slices.Values(b.Labels)), slices.Sorted(slices.Values(m.Labels))) {
+			fields = append(fields, "labels")
+		}
+		if b.StabilityLevel != "" && b.StabilityLevel != m.StabilityLevel {
+			fields = append(fields, "stability level")
+		}
+		if len(fields) > 0 {
+			changes = append(changes, metricChange{kind: changeChanged, name: name, fields: fields})
+		}
+	}
+	for name := range baseByName {
+		if _, ok := byName[name]; !ok {
+			changes = append(changes, metricChange{kind: changeRemoved, name: name})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].name < changes[j].name })
+	return changes
+}
+
+// reportBaselineDiff prints the changes to the documented metrics since the baseline
+func reportBaselineDiff(opts Options, path string, f baselineFormat) {
+	baseline, err := loadBaseline(path, f)
+	if err != nil {
+		fatalf("error loading baseline, %s", err)
+	}
+	for _, c := range diffBaseline(baseline, documentedMetrics(opts, getCheckedMetrics(opts))) {
+		log.Println(c)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cacheVersion must be bumped whenever the extraction logic changes
+const cacheVersion = "v22"
+
+type cacheEntry struct {
+	// Key identifies the source files that the metrics were extracted from
+	Key     string       `json:"key"`
+	Metrics []metricInfo `json:"metrics"`
+}
+
+// getCachedMetrics returns the cached metrics of dir, extracting them again when its source changed
+func getCachedMetrics(cacheDir, dir string, p *platform, settings string, extract func() []metricInfo) []metricInfo {
+	if cacheDir == "" {
+		return extract()
+	}
+	key, err := cacheKey(dir, p, settings)
+	if err != nil {
+		log.Printf("error computing cache key for %s, %s", dir, err)
+		return extract()
+	}
+	// Each platform and setting has its own entry since the files that are parsed and the metrics that are read from them
+	// depend on it
+	path := filepath.Join(cacheDir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%s", dir, p, settings)))))
+	if data, err := os.ReadFile(path); err == nil {
+		entry := cacheEntry{}
+		if err := json.Unmarshal(data, &entry); err == nil && entry.Key == key {
+			return entry.Metrics
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("error reading cache entry for %s, %s", dir, err)
+	}
+	metrics := extract()
+	data, err := json.Marshal(cacheEntry{Key: key, Metrics: metrics})
+	if err != nil {
+		log.Printf("error encoding cache entry for %s, %s", dir, err)
+		return metrics
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		log.Printf("error creating cache dir %s, %s", cacheDir, err)
+		return metrics
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Printf("error writing cache entry for %s, %s", dir, err)
+	}
+	return metrics
+}
+
+// cacheKey hashes the platform, the settings, and the path, modification time, and size of every go file in dir
+func cacheKey(dir string, p *platform, settings string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintln(h, cacheVersion, p, settings)
+	// ReadDir returns entries sorted by filename so the key is stable
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s %d %d\n", filepath.Join(dir, entry.Name()), info.ModTime().UnixNano(), info.Size())
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,204 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/operatorpkg/serrors"
+	"github.com/samber/lo"
+)
+
+type changeKind string
+
+const (
+	changeAdded   changeKind = "added"
+	changeRemoved changeKind = "removed"
+	changeChanged changeKind = "changed"
+)
+
+// metricChange is a difference between the metrics declared at a git ref and the metrics declared in the working tree
+type metricChange struct {
+	kind changeKind
+	name string
+	// fields are the fields of a changed metric that differ
+	fields []string
+}
+
+func (c metricChange) String() string {
+	if c.kind == changeChanged {
+		return fmt.Sprintf("%s %s (%s)", c.kind, c.name, strings.Join(c.fields, ", "))
+	}
+	return fmt.Sprintf("%s %s", c.kind, c.name)
+}
+
+// getChangedMetrics returns the differences in the metrics of the packages that changed since the git ref
+func getChangedMetrics(opts Options, ref string) ([]metricChange, []warning, error) {
+	constructors := opts.config.constructors()
+	var base, head []metricInfo
+	for _, root := range opts.roots {
+		dirs, err := getChangedPackageDirs(root, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, dir := range dirs {
+			metrics, err := getRefMetrics(constructors, root, ref, dir)
+			if err != nil {
+				return nil, nil, err
+			}
+			base = append(base, metrics...)
+			// The package may have been deleted since the ref
+			if _, err := os.Stat(dir); err == nil {
+				fset := token.NewFileSet()
+				head = append(head, getMetricsFromPackages(fset, constructors, opts.stats.parsed(getPackages(fset, dir, nil))...)...)
+			}
+		}
+	}
+	opts.stats.found(head)
+	head, warnings := skipUnsupported(opts, head)
+	base = lo.Reject(base, func(m metricInfo, _ int) bool {
+		return lo.ContainsBy(m.UnresolvedExprs, func(e unresolvedExpr) bool { return e.Unsupported })
+	})
+	return diffMetrics(base, head), warnings, nil
+}
+
+// reportChangedMetrics logs the changes to the metrics since the git ref, exiting when a metric was removed
+func reportChangedMetrics(opts Options, ref string) {
+	changes, warnings, err := getChangedMetrics(opts, ref)
+	if err != nil {
+		fatalf("error finding changed metrics, %s", err)
+	}
+	report(opts, warnings)
+	for _, c := range changes {
+		log.Println(c)
+	}
+	if removed := lo.CountBy(changes, func(c metricChange) bool { return c.kind == changeRemoved }); removed > 0 {
+		fatalf("found %d metrics that were removed since %s", removed, ref)
+	}
+}
+
+// diffMetrics reports the metrics whose type, help, or labels differ
+func diffMetrics(base, head []metricInfo) []metricChange {
+	baseByName := lo.KeyBy(base, func(m metricInfo) string { return m.qualifiedName() })
+	headByName := lo.KeyBy(head, func(m metricInfo) string { return m.qualifiedName() })
+	var changes []metricChange
+	for name, m := range headByName {
+		b, ok := baseByName[name]
+		if !ok {
+			changes = append(changes, metricChange{kind: changeAdded, name: name})
+			continue
+		}
+		var fields []string
+		if b.MetricType != m.MetricType {
+			fields = append(fields, "type")
+		}
+		if b.Help != m.Help {
+			fields = append(fields, "help")
+		}
+		if !slices.Equal(slices.Sorted(slices.Values(b.Labels)), slices.Sorted(slices.Values(m.Labels))) {
+			fields = append(fields, "labels")
+		}
+		if len(fields) > 0 {
+			changes = append(changes, metricChange{kind: changeChanged, name: name, fields: fields})
+		}
+	}
+	for name := range baseByName {
+		if _, ok := headByName[name]; !ok {
+			changes = append(changes, metricChange{kind: changeRemoved, name: name})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].name < changes[j].name })
+	return changes
+}
+
+// getChangedPackageDirs returns the directories beneath root with go files that changed since the git ref
+func getChangedPackageDirs(root, ref string) ([]string, error) {
+	changed, err := git(root, "diff", "--name-only", "--relative", ref, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(root, "ls-files", "--others", "--exclude-standard", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, path := range append(strings.Fields(string(changed)), strings.Fields(string(untracked))...) {
+		if filepath.Ext(path) == ".go" && !strings.HasSuffix(path, "_test.go") {
+			dirs = append(dirs, filepath.Join(root, filepath.Dir(path)))
+		}
+	}
+	dirs = lo.Uniq(dirs)
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// getRefMetrics extracts the metrics declared in the package in dir, beneath root, as it was at the git ref
+func getRefMetrics(constructors map[string]constructor, root, ref, dir string) ([]metricInfo, error) {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := git(root, "ls-tree", "--name-only", ref, "--", rel+string(filepath.Separator))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	packages := map[string]*ast.Package{}
+	for _, path := range strings.Fields(string(files)) {
+		if filepath.Ext(path) != ".go" || strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		src, err := git(root, "show", fmt.Sprintf("%s:./%s", ref, filepath.ToSlash(path)))
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(root, path), src, parser.AllErrors|parser.ParseComments)
+		if err != nil {
+			return nil, serrors.Wrap(fmt.Errorf("parsing, %w", err), "ref", ref, "path", path)
+		}
+		if strings.HasSuffix(file.Name.Name, "_test") {
+			continue
+		}
+		if _, ok := packages[file.Name.Name]; !ok {
+			packages[file.Name.Name] = &ast.Package{Name: file.Name.Name, Files: map[string]*ast.File{}}
+		}
+		packages[file.Name.Name].Files[filepath.Join(root, path)] = file
+	}
+	return getMetricsFromPackages(fset, constructors, lo.Map(slices.Sorted(maps.Keys(packages)), func(name string, _ int) *ast.Package { return packages[name] })...), nil
+}
+
+// git runs a git command in dir, returning its output
+func git(dir string, args ...string) ([]byte, error) {
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, serrors.Wrap(fmt.Errorf("running git, %w, %s", err, strings.TrimSpace(stderr.String())), "args", strings.Join(args, " "))
+	}
+	return out, nil
+}
@@ -0,0 +1,373 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/awslabs/operatorpkg/serrors"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"golang.org/x/mod/semver"
+	"k8s.io/kube-openapi/pkg/validation/errors"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/yaml"
+	goyaml "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+//go:embed config.yaml
+var defaultConfig []byte
+
+// configSchema is the JSON schema that a config must satisfy, catching misspelled keys that unmarshalling would ignore
+//
+//go:embed config.schema.json
+var configSchema []byte
+
+type lifecycle string
+
+const (
+	lifecycleStable         lifecycle = "stable"
+	lifecycleBeta           lifecycle = "beta"
+	lifecycleAlpha          lifecycle = "alpha"
+	lifecycleDeprecated     lifecycle = "deprecated"
+	lifecyclePendingRemoval lifecycle = "pending-removal"
+)
+
+var lifecycles = []lifecycle{lifecycleStable, lifecycleBeta, lifecycleAlpha, lifecycleDeprecated, lifecyclePendingRemoval}
+
+// defaultLegend explains what each stability level guarantees to the readers of the document
+var defaultLegend = map[lifecycle]string{
+	lifecycleStable:         "The metric's name, type, and labels won't change without a deprecation period spanning multiple minor releases.",
+	lifecycleBeta:           "The metric is unlikely to change, but its labels may be adjusted between minor releases.",
+	lifecycleAlpha:          "The metric may change or be removed in any release without notice.",
+	lifecycleDeprecated:     "The metric is still emitted but will be removed, and shouldn't be used in new dashboards or alerts.",
+	lifecyclePendingRemoval: "The metric will be removed in an upcoming release.",
+}
+
+// stabilityLevel is the rendered form of the lifecycle, e.g. "PENDING REMOVAL"
+func (l lifecycle) stabilityLevel() string {
+	return strings.ToUpper(strings.ReplaceAll(string(l), "-", " "))
+}
+
+type config struct {
+	// Metrics is keyed by either a qualified metric name or a subsystem
+	Metrics map[string]metricConfig `json:"metrics"`
+	// Legend overrides the default explanations of the stability levels that are rendered with -legend
+	Legend map[lifecycle]string `json:"legend,omitempty"`
+	// SubsystemDescriptions introduce the metrics of each subsystem under its heading, keyed by the subsystem
+	SubsystemDescriptions map[string]string `json:"subsystemDescriptions,omitempty"`
+	// PackageConstLabels are the constant labels that are injected into every metric of a package by registering them
+	// with a wrapped registerer, e.g. prometheus.WrapRegistererWith, keyed by the directory of the package relative to
+	// the path argument that it was found beneath
+	PackageConstLabels map[string]map[string]string `json:"packageConstLabels,omitempty"`
+	// Libraries are the prefixes of the metrics that are registered by libraries without a namespace or subsystem, which
+	// are split from the names of the metrics into their subsystems
+	Libraries []string `json:"libraries,omitempty"`
+	// LibraryDocs are the urls of the upstream documentation of the metrics of libraries, keyed by the library, which are
+	// linked to with -collapse-libraries-to-link
+	LibraryDocs map[string]string `json:"libraryDocs,omitempty"`
+	// LabelValues enumerate the values of labels that have a small known set of values, keyed by the label, which are
+	// rendered under the metrics that declare the label. They replace the default values of well-known labels.
+	LabelValues map[string][]string `json:"labelValues,omitempty"`
+	// UseCases group the qualified names of the metrics that answer an operator's question, keyed by the use case, e.g.
+	// disruption, which are rendered as a cheat sheet with -emit-cheatsheet
+	UseCases map[string][]string `json:"useCases,omitempty"`
+	// FeatureGates are the names of the known feature gates that metrics may be annotated as requiring
+	FeatureGates []string `json:"featureGates,omitempty"`
+	// Wrappers are the factory functions that declare metrics through the client library's constructors, e.g. factories
+	// generated from protobuf definitions, which are documented as though they were constructors
+	Wrappers []wrapper `json:"wrappers,omitempty"`
+	// Remaps reassign the namespace and subsystem of metrics whose declarations set them inconsistently, e.g. with the
+	// subsystem in the Namespace field, keyed by the qualified name of the metric as it's declared
+	Remaps map[string]remap `json:"remaps,omitempty"`
+	// TemplatedMetrics are families of metrics whose names are generated at runtime and can't be found in the source
+	TemplatedMetrics []templatedMetric `json:"templatedMetrics,omitempty"`
+}
+
+// remap is the namespace and subsystem that a metric is documented with in place of those it's declared with
+type remap struct {
+	Namespace string `json:"namespace"`
+	Subsystem string `json:"subsystem"`
+}
+
+// templatedMetric is a family of metrics that differ only by a variant in their name, e.g. one metric per capacity type
+type templatedMetric struct {
+	Namespace string     `json:"namespace,omitempty"`
+	Subsystem string     `json:"subsystem,omitempty"`
+	Name      string     `json:"name"`
+	Help      string     `json:"help"`
+	Type      metricType `json:"type,omitempty"`
+	// Dimension is what the variants represent and Name must contain it as a {dimension} placeholder
+	Dimension string   `json:"dimension"`
+	Variants  []string `json:"variants"`
+	// Expand documents a metric for each variant rather than a single entry noting the dimension
+	Expand bool `json:"expand,omitempty"`
+}
+
+func (t templatedMetric) placeholder() string {
+	return fmt.Sprintf("{%s}", t.Dimension)
+}
+
+// wrapper is a factory function that takes the fields of the metric that it declares as positional arguments
+type wrapper struct {
+	// Function is the package qualified name of the factory as it's called, e.g. requestpb.NewCounter
+	Function string     `json:"function"`
+	Type     metricType `json:"type"`
+	// Namespace and Subsystem are the fields that the factory sets itself rather than taking as arguments
+	Namespace string `json:"namespace,omitempty"`
+	Subsystem string `json:"subsystem,omitempty"`
+	// Args are the positions of the arguments that give the fields of the metric, keyed by namespace, subsystem, name,
+	// help, or labels
+	Args map[string]int `json:"args"`
+}
+
+type metricConfig struct {
+	Lifecycle lifecycle `json:"lifecycle,omitempty"`
+	// DeprecatedSince and RemovalPlanned are the versions that a deprecated metric was deprecated in and is planned to
+	// be removed in, e.g. v1.3.0
+	DeprecatedSince string `json:"deprecatedSince,omitempty"`
+	RemovalPlanned  string `json:"removalPlanned,omitempty"`
+	// Reason explains why a deprecated metric was deprecated, e.g. replaced by histogram-based timing
+	Reason string `json:"reason,omitempty"`
+	// Related are the qualified names of metrics that are meant to be used together with the metric, e.g. the limits
+	// that correspond to a metric of requests
+	Related []string `json:"related,omitempty"`
+}
+
+// loadConfig reads the config at path, falling back to the embedded config.yaml when no path is given
+func loadConfig(path string) (*config, error) {
+	data := defaultConfig
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, serrors.Wrap(fmt.Errorf("reading config, %w", err), "path", path)
+		}
+	}
+	if err := validateSchema(data); err != nil {
+		return nil, serrors.Wrap(fmt.Errorf("validating config, %w", err), "path", path)
+	}
+	cfg := &config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, serrors.Wrap(fmt.Errorf("parsing config, %w", err), "path", path)
+	}
+	// Configs that don't list the libraries still split the metrics of the well-known libraries into their subsystems,
+	// while an empty list opts out of splitting them
+	if cfg.Libraries == nil {
+		cfg.Libraries = defaultLibraries
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validateSchema validates the config against the embedded schema, reporting the line of each offending key
+func validateSchema(data []byte) error {
+	schema := &spec.Schema{}
+	if err := json.Unmarshal(configSchema, schema); err != nil {
+		return fmt.Errorf("parsing config schema, %w", err)
+	}
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return err
+	}
+	var obj any
+	if err := json.Unmarshal(jsonData, &obj); err != nil {
+		return err
+	}
+	result := validate.NewSchemaValidator(schema, nil, "", strfmt.Default).Validate(obj)
+	if result.IsValid() {
+		return nil
+	}
+	node := &goyaml.Node{}
+	if err := goyaml.Unmarshal(data, node); err != nil {
+		return err
+	}
+	var errs []error
+	for _, err := range result.Errors {
+		path := ""
+		if verr, ok := err.(*errors.Validation); ok {
+			path = verr.Name
+			// The forbidden property is reported separately from the path of the object that contains it
+			if verr.Code() == errors.UnallowedPropertyCode {
+				path = strings.TrimPrefix(fmt.Sprintf("%s.%s", verr.Name, verr.Value), ".")
+			}
+		}
+		errs = append(errs, fmt.Errorf("line %d: %s", lineOf(node, path), strings.ReplaceAll(err.Error(), " in body", "")))
+	}
+	return multierr.Combine(errs...)
+}
+
+// lineOf returns the line of the deepest node in the document that matches the path, e.g. templatedMetrics[0].name
+func lineOf(doc *goyaml.Node, path string) int {
+	if len(doc.Content) == 0 {
+		return 0
+	}
+	node, line := doc.Content[0], doc.Content[0].Line
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '.' || r == '[' || r == ']' }) {
+		var next *goyaml.Node
+		switch node.Kind {
+		case goyaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment {
+					next, line = node.Content[i+1], node.Content[i].Line
+					break
+				}
+			}
+		case goyaml.SequenceNode:
+			if i, err := strconv.Atoi(segment); err == nil && i < len(node.Content) {
+				next, line = node.Content[i], node.Content[i].Line
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return line
+}
+
+func (c *config) validate() error {
+	// Keys are validated in order so that the same error is reported for an invalid config on every run
+	for _, key := range slices.Sorted(maps.Keys(c.Metrics)) {
+		mc := c.Metrics[key]
+		if mc.Lifecycle != "" && !slices.Contains(lifecycles, mc.Lifecycle) {
+			return serrors.Wrap(fmt.Errorf("invalid lifecycle, must be one of %v", lifecycles), "metric", key, "lifecycle", mc.Lifecycle)
+		}
+		for _, version := range []string{mc.DeprecatedSince, mc.RemovalPlanned} {
+			if version != "" && !semver.IsValid(version) {
+				return serrors.Wrap(fmt.Errorf("invalid version, must be a semantic version such as v1.3.0"), "metric", key, "version", version)
+			}
+		}
+		if mc.DeprecatedSince != "" && mc.RemovalPlanned != "" && semver.Compare(mc.RemovalPlanned, mc.DeprecatedSince) <= 0 {
+			return serrors.Wrap(fmt.Errorf("removal must be planned for a version after the deprecation"), "metric", key, "deprecatedSince", mc.DeprecatedSince, "removalPlanned", mc.RemovalPlanned)
+		}
+	}
+	for _, t := range c.TemplatedMetrics {
+		if t.Dimension == "" || !strings.Contains(t.Name, t.placeholder()) {
+			return serrors.Wrap(fmt.Errorf("templated metric name must contain its dimension as a placeholder"), "name", t.Name, "dimension", t.Dimension)
+		}
+		if len(t.Variants) == 0 {
+			return serrors.Wrap(fmt.Errorf("templated metric must have at least one variant"), "name", t.Name)
+		}
+		if t.Type != "" && !slices.Contains(metricTypes, t.Type) {
+			return serrors.Wrap(fmt.Errorf("invalid type, must be one of %v", metricTypes), "name", t.Name, "type", t.Type)
+		}
+	}
+	for _, w := range c.Wrappers {
+		if !slices.Contains(metricTypes, w.Type) {
+			return serrors.Wrap(fmt.Errorf("invalid type, must be one of %v", metricTypes), "function", w.Function, "type", w.Type)
+		}
+		if _, ok := w.Args["name"]; !ok {
+			return serrors.Wrap(fmt.Errorf("wrapper must take the name of the metric as an argument"), "function", w.Function)
+		}
+		if _, ok := constructors[w.Function]; ok {
+			return serrors.Wrap(fmt.Errorf("wrapper can't replace a constructor of the client library"), "function", w.Function)
+		}
+	}
+	return nil
+}
+
+// constructors returns the constructors of the client library along with the configured wrappers
+func (c *config) constructors() map[string]constructor {
+	return lo.Assign(constructors, lo.SliceToMap(c.Wrappers, func(w wrapper) (string, constructor) {
+		return w.Function, constructor{
+			metricType: w.Type,
+			args:       w.Args,
+			fields:     lo.OmitByValues(map[string]string{"Namespace": w.Namespace, "Subsystem": w.Subsystem}, []string{""}),
+		}
+	}))
+}
+
+// legend returns the explanation of a stability level, preferring the configured explanation to the default
+func (c *config) legend(l lifecycle) string {
+	if text, ok := c.Legend[l]; ok {
+		return text
+	}
+	return defaultLegend[l]
+}
+
+// lifecycle returns the configured lifecycle of a metric, which is alpha by default
+func (c *config) lifecycle(m metricInfo) lifecycle {
+	if l := c.lookup(m, func(mc metricConfig) string { return string(mc.Lifecycle) }); l != "" {
+		return lifecycle(l)
+	}
+	return lifecycleAlpha
+}
+
+// deprecation returns the versions that a metric was deprecated in and is planned to be removed in
+func (c *config) deprecation(m metricInfo) (string, string) {
+	return c.lookup(m, func(mc metricConfig) string { return mc.DeprecatedSince }), c.lookup(m, func(mc metricConfig) string { return mc.RemovalPlanned })
+}
+
+// deprecationReason returns the configured explanation of why a metric was deprecated, which may be empty
+func (c *config) deprecationReason(m metricInfo) string {
+	return c.lookup(m, func(mc metricConfig) string { return mc.Reason })
+}
+
+// defaultLibraries are the library prefixes that are used when the config doesn't list any
+var defaultLibraries = []string{"controller_runtime", "aws_sdk_go", "client_go", "leader_election"}
+
+// defaultLabelValues are the values of the well-known labels of Karpenter's metrics
+var defaultLabelValues = map[string][]string{
+	"capacity_type": {"spot", "on-demand", "reserved"},
+	"resource_type": {"cpu", "memory", "pods", "ephemeral-storage"},
+}
+
+// regionalLabelValues are example values of the well-known labels that depend on the region
+var regionalLabelValues = map[string][]string{
+	"zone": {"us-west-2a", "us-west-2b", "us-west-2c"},
+}
+
+// labelValues returns the values of a label, preferring those in the config over the defaults of well-known labels
+func (c *config) labelValues(label string) []string {
+	if values, ok := c.LabelValues[label]; ok {
+		return values
+	}
+	if values, ok := regionalLabelValues[label]; ok {
+		return values
+	}
+	return defaultLabelValues[label]
+}
+
+// regional is whether the values of a label are the region-dependent examples of its values rather than every value
+func (c *config) regional(label string) bool {
+	_, configured := c.LabelValues[label]
+	return !configured && lo.HasKey(regionalLabelValues, label)
+}
+
+// related returns the qualified names of the metrics that are configured as related to a metric
+func (c *config) related(m metricInfo) []string {
+	return c.Metrics[m.sourceName()].Related
+}
+
+// lookup returns a field of the configuration of a metric, falling back to its builtin and subsystem configuration
+func (c *config) lookup(m metricInfo, field func(metricConfig) string) string {
+	for _, mc := range []metricConfig{c.Metrics[m.sourceName()], m.builtin, c.Metrics[m.Subsystem]} {
+		if value := field(mc); value != "" {
+			return value
+		}
+	}
+	return ""
+}
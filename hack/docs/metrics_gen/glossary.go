@@ -0,0 +1,70 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/awslabs/operatorpkg/serrors"
+	"github.com/samber/lo"
+	"sigs.k8s.io/yaml"
+)
+
+// glossary maps the domain terms that help text mentions to the urls that explain them
+type glossary map[string]string
+
+// markdownSpans match the inline code spans, links, and autolinks of help text, which terms aren't linked within
+var markdownSpans = regexp.MustCompile("`[^`]*`|\\[[^\\]]*\\]\\([^)]*\\)|<[^>\\s]+>")
+
+// loadGlossary reads the glossary at path
+func loadGlossary(path string) (glossary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, serrors.Wrap(fmt.Errorf("reading glossary, %w", err), "path", path)
+	}
+	g := glossary{}
+	if err := yaml.UnmarshalStrict(data, &g); err != nil {
+		return nil, serrors.Wrap(fmt.Errorf("parsing glossary, %w", err), "path", path)
+	}
+	for _, term := range slices.Sorted(maps.Keys(g)) {
+		if strings.TrimSpace(term) == "" || g[term] == "" {
+			return nil, serrors.Wrap(fmt.Errorf("glossary entries must map a term to a url"), "path", path, "term", term)
+		}
+	}
+	return g, nil
+}
+
+// link links the first occurrence of each glossary term in help, outside of code spans and links
+func (g glossary) link(help string) string {
+	terms := slices.SortedFunc(maps.Keys(g), func(a, b string) int { return cmp.Or(cmp.Compare(len(b), len(a)), strings.Compare(a, b)) })
+	for _, term := range terms {
+		// Spans are found again for each term so that the links to longer terms are kept as written
+		spans := markdownSpans.FindAllStringIndex(help, -1)
+		for _, match := range regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(term)+`s?\b`).FindAllStringIndex(help, -1) {
+			if lo.ContainsBy(spans, func(span []int) bool { return match[0] < span[1] && span[0] < match[1] }) {
+				continue
+			}
+			help = fmt.Sprintf("%s[%s](%s)%s", help[:match[0]], help[match[0]:match[1]], g[term], help[match[1]:])
+			break
+		}
+	}
+	return help
+}
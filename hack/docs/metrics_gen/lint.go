@@ -0,0 +1,425 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// warning is an advisory finding about the extracted metrics
+type warning struct {
+	rule    string
+	message string
+	// metric is the qualified name of the metric that the warning is about and position is where it's declared, which
+	// are unset for warnings about several metrics
+	metric   string
+	position token.Position
+	// strict warnings indicate a bug rather than a matter of style and are fatal with -strict
+	strict bool
+}
+
+func (w warning) String() string {
+	return fmt.Sprintf("[%s] %s", w.rule, w.message)
+}
+
+// maxExitCode caps the exit code of a run that fails on its warnings, since exit codes above it are reserved by shells
+const maxExitCode = 125
+
+// report logs the warnings, exiting with the number of fatal warnings when there are any
+func report(opts Options, warnings []warning) {
+	if err := writeReport(os.Stdout, opts.reportFormat, warnings, fatalWarnings(opts, warnings)); err != nil {
+		fatalf("error writing report, %s", err)
+	}
+	if fatal := fatalWarnings(opts, warnings); len(fatal) > 0 {
+		exitf(min(len(fatal), maxExitCode), "found %d warnings that are fatal with %s", len(fatal), lo.Ternary(opts.failOnWarnings, "-fail-on-warnings", "-strict"))
+	}
+}
+
+type reportFormat string
+
+const (
+	reportFormatText reportFormat = "text"
+	reportFormatJSON reportFormat = "json"
+)
+
+var reportFormats = []reportFormat{reportFormatText, reportFormatJSON}
+
+// reportEntry is a warning as it's written by -report-format json
+type reportEntry struct {
+	// Severity is error for the warnings that fail the run and warning for the others
+	Severity string `json:"severity"`
+	Metric   string `json:"metric,omitempty"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+	Position string `json:"position,omitempty"`
+}
+
+// writeReport logs text warnings or writes JSON warnings to out
+func writeReport(out io.Writer, format reportFormat, warnings, fatal []warning) error {
+	if format != reportFormatJSON {
+		for _, w := range warnings {
+			log.Printf("warning: %s", w)
+		}
+		return nil
+	}
+	entries := lo.Map(warnings, func(w warning, _ int) reportEntry {
+		entry := reportEntry{Severity: "warning", Metric: w.metric, Rule: w.rule, Message: w.message}
+		if slices.Contains(fatal, w) {
+			entry.Severity = "error"
+		}
+		if w.position.IsValid() {
+			entry.Position = w.position.String()
+		}
+		return entry
+	})
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// fatalWarnings returns the warnings that fail the run with -strict or -fail-on-warnings
+func fatalWarnings(opts Options, warnings []warning) []warning {
+	switch {
+	case opts.failOnWarnings:
+		return warnings
+	case opts.strict:
+		return lo.Filter(warnings, func(w warning, _ int) bool { return w.strict })
+	}
+	return nil
+}
+
+// lint runs the advisory checks against the extracted metrics
+func lint(metrics []metricInfo) []warning {
+	return slices.Concat(lintCounterSuffixes(metrics), lintReservedLabels(metrics), lintAnnotations(metrics), lintUnresolved(metrics))
+}
+
+// reservedLabels are the labels that Prometheus or the client library set themselves
+var reservedLabels = []string{"job", "instance", "le", "quantile", "__name__"}
+
+// lintCounterSuffixes flags counter families where only some members end in _total
+func lintCounterSuffixes(metrics []metricInfo) []warning {
+	counters := lo.Filter(metrics, func(m metricInfo, _ int) bool { return m.MetricType == metricTypeCounter })
+	families := lo.GroupBy(counters, func(m metricInfo) string {
+		return strings.Join(lo.Compact([]string{m.Namespace, m.Subsystem}), "_")
+	})
+	var warnings []warning
+	keys := lo.Keys(families)
+	sort.Strings(keys)
+	for _, family := range keys {
+		withoutSuffix, withSuffix := lo.FilterReject(families[family], func(m metricInfo, _ int) bool {
+			return !strings.HasSuffix(m.Name, "_total")
+		})
+		if len(withSuffix) == 0 || len(withoutSuffix) == 0 {
+			continue
+		}
+		warnings = append(warnings, warning{
+			rule: "counter-suffix",
+			message: fmt.Sprintf("counter family %s mixes _total and non-_total names, missing the suffix: %s", family,
+				strings.Join(lo.Map(withoutSuffix, func(m metricInfo, _ int) string { return m.qualifiedName() }), ", ")),
+		})
+	}
+	return warnings
+}
+
+// lintLabelConflicts flags metrics that are declared more than once with different labels
+func lintLabelConflicts(metrics []metricInfo) []warning {
+	declared := lo.Filter(metrics, func(m metricInfo, _ int) bool { return !m.Synthetic && m.Labels != nil })
+	byName := lo.GroupBy(declared, func(m metricInfo) string { return m.qualifiedName() })
+	var warnings []warning
+	names := lo.Keys(byName)
+	sort.Strings(names)
+	for _, name := range names {
+		first := byName[name][0]
+		for _, m := range byName[name][1:] {
+			if slices.Equal(slices.Sorted(slices.Values(first.Labels)), slices.Sorted(slices.Values(m.Labels))) {
+				continue
+			}
+			warnings = append(warnings, warning{
+				rule:     "label-conflict",
+				metric:   name,
+				position: m.Position,
+				message: fmt.Sprintf("%s is declared with labels [%s] at %s and with labels [%s] at %s", name,
+					strings.Join(first.Labels, ", "), first.Position, strings.Join(m.Labels, ", "), m.Position),
+				strict: true,
+			})
+		}
+	}
+	return warnings
+}
+
+// lintCrossRootDupes flags metrics that are declared beneath more than one root
+func lintCrossRootDupes(metrics []metricInfo) []warning {
+	declared := lo.Filter(metrics, func(m metricInfo, _ int) bool { return !m.Synthetic })
+	byName := lo.GroupBy(declared, func(m metricInfo) string { return m.qualifiedName() })
+	var warnings []warning
+	for _, name := range slices.Sorted(maps.Keys(byName)) {
+		first := byName[name][0]
+		for _, m := range lo.UniqBy(byName[name][1:], func(m metricInfo) string { return m.Root }) {
+			if m.Root == first.Root {
+				continue
+			}
+			warnings = append(warnings, warning{
+				rule:     "cross-root-dupe",
+				metric:   name,
+				position: m.Position,
+				message:  fmt.Sprintf("%s is declared beneath both %s at %s and %s at %s", name, first.Root, first.Position, m.Root, m.Position),
+				strict:   true,
+			})
+		}
+	}
+	return warnings
+}
+
+// lintReservedLabels flags metrics that declare a label that's reserved by Prometheus
+func lintReservedLabels(metrics []metricInfo) []warning {
+	var warnings []warning
+	for _, m := range metrics {
+		for _, label := range lo.Intersect(m.Labels, reservedLabels) {
+			warnings = append(warnings, warning{
+				rule:     "reserved-label",
+				metric:   m.qualifiedName(),
+				position: m.Position,
+				message:  fmt.Sprintf("%s at %s declares the reserved label %s", m.qualifiedName(), m.Position, label),
+				strict:   true,
+			})
+		}
+	}
+	return warnings
+}
+
+// lintRelated flags related metrics in the config that aren't declared
+func lintRelated(cfg *config, metrics []metricInfo) []warning {
+	declared := lo.SliceToMap(metrics, func(m metricInfo) (string, bool) { return m.sourceName(), true })
+	var warnings []warning
+	for _, m := range metrics {
+		for _, name := range cfg.related(m) {
+			if declared[name] {
+				continue
+			}
+			warnings = append(warnings, warning{
+				rule:     "related",
+				metric:   m.qualifiedName(),
+				position: m.Position,
+				message:  fmt.Sprintf("%s is configured as related to %s but isn't declared", name, m.sourceName()),
+				strict:   true,
+			})
+		}
+	}
+	return warnings
+}
+
+// lintUnusedConfig flags the entries of the config that don't match anything that was extracted
+func lintUnusedConfig(cfg *config, metrics []metricInfo) []warning {
+	names := lo.SliceToMap(metrics, func(m metricInfo) (string, struct{}) { return m.sourceName(), struct{}{} })
+	subsystems := lo.SliceToMap(metrics, func(m metricInfo) (string, struct{}) { return m.Subsystem, struct{}{} })
+	labels := map[string]struct{}{}
+	for _, m := range metrics {
+		for _, label := range slices.Concat(m.Labels, slices.Collect(maps.Keys(m.ConstLabels))) {
+			labels[label] = struct{}{}
+		}
+	}
+	packages := lo.SliceToMap(lo.Reject(metrics, func(m metricInfo, _ int) bool { return m.Synthetic }), func(m metricInfo) (string, struct{}) {
+		return m.controller(), struct{}{}
+	})
+	remapped := lo.SliceToMap(metrics, func(m metricInfo) (string, struct{}) { return m.remappedFrom, struct{}{} })
+	synthetic := lo.SliceToMap(lo.Filter(metrics, func(m metricInfo, _ int) bool { return m.Synthetic }), func(m metricInfo) (string, struct{}) {
+		return m.sourceName(), struct{}{}
+	})
+	gates := lo.SliceToMap(metrics, func(m metricInfo) (string, struct{}) { return m.Annotations["feature-gate"], struct{}{} })
+	// A templated metric is unused when every metric that it generates is shadowed by a declared metric
+	templated := map[string]templatedMetric{}
+	for _, t := range cfg.TemplatedMetrics {
+		templated[metricInfo{Namespace: t.Namespace, Subsystem: t.Subsystem, Name: t.Name}.qualifiedName()] = t
+	}
+	generated := func(key string) bool {
+		t := templated[key]
+		if !t.Expand {
+			return lo.HasKey(synthetic, key)
+		}
+		return lo.SomeBy(t.Variants, func(variant string) bool {
+			return lo.HasKey(synthetic, strings.ReplaceAll(key, t.placeholder(), variant))
+		})
+	}
+	var warnings []warning
+	unused := func(section string, keys []string, matches func(string) bool, target string) {
+		for _, key := range slices.Sorted(slices.Values(keys)) {
+			if matches(key) {
+				continue
+			}
+			warnings = append(warnings, warning{
+				rule:    "unused-config",
+				message: fmt.Sprintf("%s entry %s doesn't match any %s", section, key, target),
+			})
+		}
+	}
+	unused("metrics", lo.Keys(cfg.Metrics), func(key string) bool { return lo.HasKey(names, key) || lo.HasKey(subsystems, key) }, "metric or subsystem")
+	unused("subsystemDescriptions", lo.Keys(cfg.SubsystemDescriptions), func(key string) bool { return lo.HasKey(subsystems, key) }, "subsystem")
+	unused("libraryDocs", lo.Keys(cfg.LibraryDocs), func(key string) bool { return lo.HasKey(subsystems, key) }, "subsystem")
+	unused("labelValues", lo.Keys(cfg.LabelValues), func(key string) bool { return lo.HasKey(labels, key) }, "label")
+	unused("useCases", lo.Uniq(lo.Flatten(lo.Values(cfg.UseCases))), func(key string) bool { return lo.HasKey(names, key) }, "metric")
+	unused("related", lo.Uniq(lo.FlatMap(lo.Values(cfg.Metrics), func(mc metricConfig, _ int) []string { return mc.Related })),
+		func(key string) bool { return lo.HasKey(names, key) }, "metric")
+	unused("packageConstLabels", lo.Keys(cfg.PackageConstLabels), func(key string) bool { return lo.HasKey(packages, key) }, "package")
+	unused("remaps", lo.Keys(cfg.Remaps), func(key string) bool { return lo.HasKey(remapped, key) }, "metric")
+	unused("templatedMetrics", lo.Keys(templated), generated, "undeclared metric")
+	unused("featureGates", lo.Uniq(cfg.FeatureGates), func(key string) bool { return lo.HasKey(gates, key) }, "feature-gate annotation")
+	return warnings
+}
+
+// lintPositions flags the non-synthetic metrics that were extracted without a position
+func lintPositions(metrics []metricInfo) []warning {
+	var warnings []warning
+	for _, m := range metrics {
+		if m.Synthetic || m.Position.IsValid() {
+			continue
+		}
+		warnings = append(warnings, warning{
+			rule:    "position",
+			metric:  m.qualifiedName(),
+			message: fmt.Sprintf("%s was extracted from the source without a position", m.qualifiedName()),
+			strict:  true,
+		})
+	}
+	return warnings
+}
+
+// lintUnclassifiedSubsystems flags the subsystems that are neither in the sort order nor titled
+func lintUnclassifiedSubsystems(t titles, metrics []metricInfo) []warning {
+	counts := lo.CountValuesBy(metrics, func(m metricInfo) string { return m.Subsystem })
+	var warnings []warning
+	for _, subsystem := range slices.Sorted(maps.Keys(counts)) {
+		if lo.HasKey(subSystemSortOrder, subsystem) || lo.HasKey(t.Subsystems, subsystem) {
+			continue
+		}
+		warnings = append(warnings, warning{
+			rule: "unclassified-subsystem",
+			message: fmt.Sprintf("subsystem %s of %d %s is neither in the sort order nor titled, so it's sorted with the default priority and titled %q",
+				subsystem, counts[subsystem], lo.Ternary(counts[subsystem] == 1, "metric", "metrics"), subsystemTitle(subsystem)),
+		})
+	}
+	return warnings
+}
+
+// lintUseCases flags the metrics listed under a use case that aren't declared
+func lintUseCases(cfg *config, metrics []metricInfo) []warning {
+	declared := lo.SliceToMap(metrics, func(m metricInfo) (string, struct{}) { return m.sourceName(), struct{}{} })
+	var warnings []warning
+	for _, useCase := range slices.Sorted(maps.Keys(cfg.UseCases)) {
+		for _, name := range cfg.UseCases[useCase] {
+			if lo.HasKey(declared, name) {
+				continue
+			}
+			warnings = append(warnings, warning{
+				rule:    "use-case",
+				message: fmt.Sprintf("%s is listed under the use case %s but isn't declared", name, useCase),
+				strict:  true,
+			})
+		}
+	}
+	return warnings
+}
+
+// lintRelatedStability flags stable metrics that are related to metrics that aren't stable
+func lintRelatedStability(cfg *config, metrics []metricInfo) []warning {
+	declared := lo.KeyBy(metrics, func(m metricInfo) string { return m.sourceName() })
+	var warnings []warning
+	for _, m := range metrics {
+		if cfg.lifecycle(m) != lifecycleStable {
+			continue
+		}
+		for _, name := range cfg.related(m) {
+			target, ok := declared[name]
+			if !ok || cfg.lifecycle(target) == lifecycleStable {
+				continue
+			}
+			warnings = append(warnings, warning{
+				rule:     "related-stability",
+				metric:   m.qualifiedName(),
+				position: m.Position,
+				message:  fmt.Sprintf("%s is stable but is related to %s, which is %s", m.sourceName(), name, cfg.lifecycle(target).stabilityLevel()),
+			})
+		}
+	}
+	return warnings
+}
+
+// lintNameLength flags metrics whose qualified names are longer than limit characters, which is disabled when limit is zero
+func lintNameLength(metrics []metricInfo, limit int) []warning {
+	if limit <= 0 {
+		return nil
+	}
+	var warnings []warning
+	for _, m := range metrics {
+		if name := m.qualifiedName(); len(name) > limit {
+			warnings = append(warnings, warning{
+				rule:     "name-length",
+				metric:   name,
+				position: m.Position,
+				message:  fmt.Sprintf("%s is %d characters long, longer than the limit of %d", name, len(name), limit),
+				strict:   true,
+			})
+		}
+	}
+	return warnings
+}
+
+// lintDuplicateHelp flags help text that's shared by more than one metric
+func lintDuplicateHelp(metrics []metricInfo) []warning {
+	// Missing help is flagged by its own lint
+	byHelp := lo.GroupBy(lo.Filter(metrics, func(m metricInfo, _ int) bool { return m.Help != "" }), func(m metricInfo) string { return m.Help })
+	var warnings []warning
+	helps := lo.Keys(byHelp)
+	sort.Strings(helps)
+	for _, help := range helps {
+		names := lo.Uniq(lo.Map(byHelp[help], func(m metricInfo, _ int) string { return m.qualifiedName() }))
+		if len(names) < 2 {
+			continue
+		}
+		warnings = append(warnings, warning{
+			rule:    "duplicate-help",
+			message: fmt.Sprintf("%q is the help of %s", help, strings.Join(names, ", ")),
+			strict:  true,
+		})
+	}
+	return warnings
+}
+
+// lintUnresolved flags metrics whose fields are set by identifiers that couldn't be resolved
+func lintUnresolved(metrics []metricInfo) []warning {
+	var warnings []warning
+	for _, m := range metrics {
+		if len(m.Unresolved) == 0 {
+			continue
+		}
+		warnings = append(warnings, warning{
+			rule:     "unresolved",
+			metric:   m.qualifiedName(),
+			position: m.Position,
+			message:  fmt.Sprintf("%s at %s is documented with unresolved identifiers for %s", m.qualifiedName(), m.Position, strings.Join(m.Unresolved, ", ")),
+		})
+	}
+	return warnings
+}
@@ -15,18 +15,27 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"io"
 	"io/fs"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/awslabs/operatorpkg/serrors"
 	"github.com/samber/lo"
@@ -35,121 +44,826 @@ import (
 )
 
 type metricInfo struct {
-	namespace string
-	subsystem string
-	name      string
-	help      string
-}
-
-var (
-	stableMetrics = []string{"controller_runtime", "aws_sdk_go", "client_go", "leader_election", "interruption", "cluster_state", "workqueue", "karpenter_build_info", "karpenter_nodepool_usage", "karpenter_nodepool_limit",
-		"karpenter_nodeclaims_terminated_total", "karpenter_nodeclaims_created_total", "karpenter_nodes_terminated_total", "karpenter_nodes_created_total", "karpenter_pods_startup_duration_seconds",
-		"karpenter_scheduler_scheduling_duration_seconds", "karpenter_provisioner_scheduling_duration_seconds", "karpenter_nodepool_allowed_disruptions", "karpenter_voluntary_disruption_decisions_total"}
-	betaMetrics = []string{"status_condition", "cloudprovider", "cloudprovider_batcher", "karpenter_nodeclaims_termination_duration_seconds", "karpenter_nodeclaims_instance_termination_duration_seconds",
-		"karpenter_nodes_total_pod_requests", "karpenter_nodes_total_pod_limits", "karpenter_nodes_total_daemon_requests", "karpenter_nodes_total_daemon_limits", "karpenter_nodes_termination_duration_seconds",
-		"karpenter_nodes_system_overhead", "karpenter_nodes_allocatable", "karpenter_pods_state", "karpenter_scheduler_queue_depth", "karpenter_voluntary_disruption_queue_failures_total",
-		"karpenter_voluntary_disruption_decision_evaluation_duration_seconds", "karpenter_voluntary_disruption_eligible_nodes", "karpenter_voluntary_disruption_consolidation_timeouts_total"}
+	Namespace  string     `json:"namespace,omitempty"`
+	Subsystem  string     `json:"subsystem,omitempty"`
+	Name       string     `json:"name"`
+	Help       string     `json:"help"`
+	MetricType metricType `json:"metricType,omitempty"`
+	// ValueType is the Go type of the value of a metric that was declared with a known constructor
+	ValueType string `json:"valueType,omitempty"`
+	// Dimension and Variants are set for a templated family that is documented as a single entry
+	Dimension string   `json:"dimension,omitempty"`
+	Variants  []string `json:"variants,omitempty"`
+	// Synthetic metrics are added by convention or config rather than being extracted from the source
+	Synthetic bool `json:"synthetic,omitempty"`
+	// Root is the path argument that the metric was found beneath and PkgPath is the directory of its package
+	Root    string `json:"root,omitempty"`
+	PkgPath string `json:"pkgPath,omitempty"`
+	// Labels are the variable labels of a vector metric, nil when they couldn't be resolved from the source
+	Labels []string `json:"labels"`
+	// ConstLabels are the constant labels of the metric that could be resolved from the source
+	ConstLabels map[string]string `json:"constLabels,omitempty"`
+	// Buckets are the upper bounds of the buckets of a histogram, nil when they couldn't be resolved from the source
+	Buckets []float64 `json:"buckets,omitempty"`
+	// Position is where the metric is declared in the source, it's invalid for synthetic metrics
+	Position token.Position `json:"position"`
+	// End is where the declaration of the metric ends in the source, it's invalid for synthetic metrics
+	End token.Position `json:"end"`
+	// Unresolved are the Opts fields whose identifiers couldn't be resolved and are documented by the identifier instead,
+	// or ConstLabels when some of the constant labels couldn't be resolved and are left out
+	Unresolved []string `json:"unresolved,omitempty"`
+	// UnresolvedExprs are the expressions that the Unresolved fields are set by, which are reported by -audit
+	UnresolvedExprs []unresolvedExpr `json:"unresolvedExprs,omitempty"`
+	// Unregistered is set when the variable that the metric is assigned to isn't passed to a registration call in its
+	// package, which is a heuristic since metrics can be registered indirectly
+	Unregistered bool `json:"unregistered,omitempty"`
+	// BuildConstraint is the build constraint of the file that declares the metric when it's platform specific
+	BuildConstraint string `json:"buildConstraint,omitempty"`
+	// sourceNamespace is the declared namespace of a metric whose namespace was overridden
+	sourceNamespace string
+	// remappedFrom is the qualified name that a remapped metric is declared with
+	remappedFrom string
+	// builtin is the configuration that a synthetic metric is known to have, e.g. the deprecation of a legacy metric,
+	// which takes precedence over the configuration for its subsystem but not for its qualified name
+	builtin metricConfig
+	// Annotations are the //metric: comments on the declaration, keyed by their names
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// unresolvedExpr is an expression that a field of a metric is set by which couldn't be resolved to a value
+type unresolvedExpr struct {
+	Field    string         `json:"field"`
+	Expr     string         `json:"expr"`
+	Position token.Position `json:"position"`
+	// Unsupported is set when the expression isn't a kind that can be resolved, e.g. a call to an unknown function,
+	// rather than an identifier without a constant or mapping
+	Unsupported bool `json:"unsupported,omitempty"`
+}
+
+type metricType string
+
+const (
+	metricTypeCounter   metricType = "counter"
+	metricTypeGauge     metricType = "gauge"
+	metricTypeHistogram metricType = "histogram"
+	metricTypeSummary   metricType = "summary"
 )
 
+var metricTypes = []metricType{metricTypeCounter, metricTypeGauge, metricTypeHistogram, metricTypeSummary}
+
+// valueTypes are the Go types of the values of each type of metric
+var valueTypes = map[metricType]string{
+	metricTypeCounter:   "float64",
+	metricTypeGauge:     "float64",
+	metricTypeHistogram: "float64 sum, uint64 count",
+	metricTypeSummary:   "float64 sum, uint64 count",
+}
+
+type constructor struct {
+	metricType metricType
+	// optsIndex is the index of the argument that holds the metric's Opts
+	optsIndex int
+	// labelled constructors take the variable labels of the metric in the argument that follows its Opts
+	labelled bool
+	// args are the positions of the arguments that a wrapper takes the fields of the metric as in place of Opts, keyed
+	// by namespace, subsystem, name, help, or labels
+	args map[string]int
+	// fields are the Opts fields that a wrapper sets itself rather than taking as arguments, e.g. its Namespace
+	fields map[string]string
+	// registers constructors register the metric with the registerer that they're given, so the metric needn't be
+	// registered separately
+	registers bool
+}
+
+// constructors are the functions that metrics are declared with, keyed by their package qualified name
+var constructors = map[string]constructor{
+	"prometheus.NewCounter":            {metricType: metricTypeCounter},
+	"prometheus.NewCounterVec":         {metricType: metricTypeCounter, labelled: true},
+	"prometheus.NewCounterFunc":        {metricType: metricTypeCounter},
+	"prometheus.NewGauge":              {metricType: metricTypeGauge},
+	"prometheus.NewGaugeVec":           {metricType: metricTypeGauge, labelled: true},
+	"prometheus.NewGaugeFunc":          {metricType: metricTypeGauge},
+	"prometheus.NewHistogram":          {metricType: metricTypeHistogram},
+	"prometheus.NewHistogramVec":       {metricType: metricTypeHistogram, labelled: true},
+	"prometheus.NewSummary":            {metricType: metricTypeSummary},
+	"prometheus.NewSummaryVec":         {metricType: metricTypeSummary, labelled: true},
+	"opmetrics.NewPrometheusCounter":   {metricType: metricTypeCounter, optsIndex: 1, labelled: true, registers: true},
+	"opmetrics.NewPrometheusGauge":     {metricType: metricTypeGauge, optsIndex: 1, labelled: true, registers: true},
+	"opmetrics.NewPrometheusHistogram": {metricType: metricTypeHistogram, optsIndex: 1, labelled: true, registers: true},
+	"opmetrics.NewPrometheusSummary":   {metricType: metricTypeSummary, optsIndex: 1, labelled: true, registers: true},
+	"promauto.NewCounter":              {metricType: metricTypeCounter, registers: true},
+	"promauto.NewCounterVec":           {metricType: metricTypeCounter, labelled: true, registers: true},
+	"promauto.NewCounterFunc":          {metricType: metricTypeCounter, registers: true},
+	"promauto.NewGauge":                {metricType: metricTypeGauge, registers: true},
+	"promauto.NewGaugeVec":             {metricType: metricTypeGauge, labelled: true, registers: true},
+	"promauto.NewGaugeFunc":            {metricType: metricTypeGauge, registers: true},
+	"promauto.NewHistogram":            {metricType: metricTypeHistogram, registers: true},
+	"promauto.NewHistogramVec":         {metricType: metricTypeHistogram, labelled: true, registers: true},
+	"promauto.NewSummary":              {metricType: metricTypeSummary, registers: true},
+	"promauto.NewSummaryVec":           {metricType: metricTypeSummary, labelled: true, registers: true},
+}
+
+// factories return a factory whose methods are the constructors of their package
+var factories = []string{"promauto.With"}
+
 func (i metricInfo) qualifiedName() string {
-	return strings.Join(lo.Compact([]string{i.namespace, i.subsystem, i.name}), "_")
+	return strings.Join(lo.Compact([]string{i.Namespace, i.Subsystem, i.Name}), "_")
+}
+
+// sourceName is the qualified name of the metric as it's declared, which it's configured by
+func (i metricInfo) sourceName() string {
+	if i.sourceNamespace == "" {
+		return i.qualifiedName()
+	}
+	return strings.Join(lo.Compact([]string{i.sourceNamespace, i.Subsystem, i.Name}), "_")
+}
+
+// library is whether the metric is registered by a library
+func (i metricInfo) library(cfg *config) bool {
+	return slices.Contains(cfg.Libraries, i.Subsystem) || (i.Namespace == "" && i.Subsystem == "")
+}
+
+// controller is the package that declares the metric, relative to its root
+func (i metricInfo) controller() string {
+	if rel, err := filepath.Rel(i.Root, i.PkgPath); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.Base(i.PkgPath)
+}
+
+// Options configures a single generation run
+type Options struct {
+	config *config
+	// roots are the directories that are walked when searching for metric declarations
+	roots []string
+	// commit is the source commit that is recorded in a footer comment of the generated document
+	commit string
+	// check verifies that the output file is up to date rather than writing it
+	check bool
+	// format is how the generated document is rendered
+	format format
+	// groupBy is the property that metrics are organized into sections by
+	groupBy groupBy
+	// compact omits the blank lines between metric entries
+	compact bool
+	// excludeDeprecated leaves the deprecated metrics out of the generated document
+	excludeDeprecated bool
+	// namespace limits the documented metrics to those of the namespace, before the subsystems are filtered, all
+	// namespaces are documented when empty
+	namespace string
+	// onlySubsystems limits the documented metrics to those of the subsystems, all subsystems are documented when empty
+	onlySubsystems []string
+	// excludeSubsystems leaves the metrics of the subsystems out of the generated document, after onlySubsystems applies
+	excludeSubsystems []string
+	// includeInternal documents the metrics annotated with //metric:internal, which are left out by default
+	includeInternal bool
+	// groupLibraries documents the metrics of libraries under a single section after the metrics of Karpenter
+	groupLibraries bool
+	// sortLibrariesLast moves the metrics of libraries after the metrics of Karpenter, preserving the order within each
+	sortLibrariesLast bool
+	// collapseLibraries links to the upstream documentation of the libraries that it's configured for rather than
+	// listing their metrics
+	collapseLibraries bool
+	// legend renders a section explaining the stability levels after the introduction
+	legend bool
+	// alphaBanner renders a warning callout under each alpha metric
+	alphaBanner bool
+	// strikethroughDeprecated strikes through the headings of deprecated and pending removal metrics
+	strikethroughDeprecated bool
+	// minStabilityForRules is the lowest stability level of the metrics that recording rules are emitted for, beta when
+	// unset, so that rules don't depend on metrics that may be removed without notice
+	minStabilityForRules lifecycle
+	// emitGraph renders a section with a Mermaid graph of the related metrics after the metrics
+	emitGraph bool
+	// queryHints renders a suggested PromQL query for each metric based on its type
+	queryHints bool
+	// collapsibleHelp is the length that help text is collapsed into a details element beyond, with a truncated summary,
+	// help text isn't collapsed when zero
+	collapsibleHelp int
+	// escapeMarkdown escapes the characters in help text that markdown would interpret as emphasis
+	escapeMarkdown bool
+	// valueTypes renders the Go type of the value of each metric where it can be inferred from its constructor
+	valueTypes bool
+	// buckets renders the bucket boundaries of each histogram where they can be resolved
+	buckets bool
+	// constLabels renders the constant labels of each metric
+	constLabels bool
+	// glossary links the first occurrence of each of its terms in help text to the url that explains it
+	glossary glossary
+	// titles translate the titles of subsystems and sections of the document
+	titles titles
+	// sourceSnippets renders the source of the declaration of each metric in a code block under its entry
+	sourceSnippets bool
+	// checkRegistration warns about the metrics that are declared but apparently never registered
+	checkRegistration bool
+	// strictPositions fails when a metric extracted from the source is without a position, which synthetic metrics are
+	// exempt from
+	strictPositions bool
+	// reportUnusedConfig warns about the entries of the config that don't match any of the extracted metrics
+	reportUnusedConfig bool
+	// derivedSeries renders the unit of each series that a histogram or summary is exposed as
+	derivedSeries bool
+	// cacheDir is where the metrics extracted from each package are cached between runs, caching is disabled when unset
+	cacheDir string
+	// splitBySubsystem writes a document per subsystem into the output directory rather than a single document
+	splitBySubsystem bool
+	// incremental only rewrites the output files whose content changed, preserving the modification times of the others
+	incremental bool
+	// strict makes the findings that indicate a bug, rather than a matter of style, fatal
+	strict bool
+	// noSynthetic only documents the metrics that are extracted from the source, leaving out the metrics that are added
+	// by convention or config
+	noSynthetic bool
+	// structTagKey is the key of the struct field tags that metrics are read from, struct tags aren't read when empty
+	structTagKey string
+	// reportFormat is how the warnings about the metrics are written
+	reportFormat reportFormat
+	// failOnWarnings makes every warning fatal, including those that are a matter of style
+	failOnWarnings bool
+	// platform limits the documented metrics to those built for it, all metrics are documented when unset
+	platform *platform
+	// relaxFatals skips the metrics that are set by unsupported expressions with a warning rather than failing, e.g.
+	// while migrating to a library whose declarations can't be resolved yet
+	relaxFatals bool
+	// detectCrossRootDupes flags metrics that are declared beneath more than one of the roots, e.g. by both Karpenter
+	// and the provider, which would fail to register
+	detectCrossRootDupes bool
+	// lintDuplicateHelp flags distinct metrics that share the same help text
+	lintDuplicateHelp bool
+	// maxNameLength is the length that qualified metric names are flagged for exceeding, names aren't checked when zero
+	maxNameLength int
+	// liveScrape is the url of a running metrics endpoint that the documented metrics are compared with
+	liveScrape string
+	// namespaceOverrides rewrites the namespaces of the documented metrics, e.g. when shipping under a different brand
+	namespaceOverrides map[string]string
+	// stats are collected about the run when set
+	stats *generationStats
+	// transform post-processes the extracted metrics, e.g. to rename namespaces in a wrapper binary. It runs after the
+	// metrics are deduped and their names are normalized, and before they're sorted, so it needn't preserve any order.
+	transform func([]metricInfo) []metricInfo
 }
 
 // metrics_gen_docs is used to parse the source code for Prometheus metrics and automatically generate markdown documentation
 // based on the naming and help provided in the source code.
-
 func main() {
+	opts := Options{namespaceOverrides: map[string]string{}}
+	var audit, matrix bool
+	var matrixInputs []matrixInput
+	var configPath, glossaryPath, titlesPath, deprecationNoticePath, labelSchemaPath, cheatSheetPath, platformFlag, cpuProfile, memProfile, outputDir, formatsFlag, filenameTemplate, onlyChanged, diffBaselinePath, baselineFormatFlag string
+	var printStats, scanStructTags bool
+	var structTagKey string
+	flag.StringVar(&configPath, "config", "", "path to a config file describing metric stability, defaults to the embedded config.yaml")
+	flag.StringVar(&opts.commit, "commit", "", "git commit of the parsed source, recorded in a footer of the generated document when set")
+	flag.BoolVar(&opts.check, "check", false, "verify that the output file is up to date instead of writing it")
+	flag.StringVar((*string)(&opts.format), "format", string(formatMarkdown), fmt.Sprintf("how the generated document is rendered, one of %v", formats))
+	flag.StringVar(&outputDir, "output-dir", "", "directory to write a document per format into, in which case every path argument is parsed and none is the output path")
+	flag.StringVar(&formatsFlag, "formats", "", fmt.Sprintf("comma separated formats to write into -output-dir from a single parse, each one of %v, defaults to -format", formats))
+	flag.StringVar(&filenameTemplate, "filename-template", "metrics.{ext}", "file name of each document written into -output-dir, where {ext} is replaced by the extension of its format")
+	flag.StringVar((*string)(&opts.groupBy), "group-by", string(groupBySubsystem), fmt.Sprintf("how metrics are organized into sections, one of %v", groupBys))
+	flag.BoolVar(&opts.compact, "compact", false, "render without blank lines between metric entries")
+	flag.BoolVar(&opts.excludeDeprecated, "exclude-deprecated", false, "leave DEPRECATED metrics out of the generated document")
+	flag.StringVar(&opts.namespace, "namespace", "", "only document the metrics of this namespace, e.g. karpenter for the first-party metrics, which applies before the subsystem filters")
+	flag.Func("only-subsystem", "only document the metrics of this subsystem, may be repeated", func(s string) error {
+		opts.onlySubsystems = append(opts.onlySubsystems, s)
+		return nil
+	})
+	flag.Func("exclude-subsystem", "leave the metrics of this subsystem out of the generated document, may be repeated and applies after -only-subsystem", func(s string) error {
+		opts.excludeSubsystems = append(opts.excludeSubsystems, s)
+		return nil
+	})
+	flag.BoolVar(&opts.includeInternal, "include-internal", false, "document the metrics annotated with //metric:internal, e.g. for internal builds")
+	flag.BoolVar(&opts.groupLibraries, "group-libraries", false, "document the metrics of the libraries listed in the config under a single section")
+	flag.BoolVar(&opts.sortLibrariesLast, "sort-libraries-last", false, "document the metrics of libraries after the metrics of Karpenter rather than sorting them among its subsystems")
+	flag.BoolVar(&opts.collapseLibraries, "collapse-libraries-to-link", false, "link to the upstream documentation of the libraries that it's configured for rather than listing their metrics")
+	flag.BoolVar(&opts.legend, "legend", false, "render a section explaining the stability levels after the introduction")
+	flag.BoolVar(&opts.alphaBanner, "alpha-banner", false, "render a warning callout under each ALPHA metric")
+	flag.BoolVar(&opts.strikethroughDeprecated, "strikethrough-deprecated", false, "strike through the headings of DEPRECATED and PENDING REMOVAL metrics")
+	flag.StringVar((*string)(&opts.minStabilityForRules), "min-stability-for-rules", string(lifecycleBeta), fmt.Sprintf("lowest stability level of the metrics that -format %s emits rules for, one of %v", formatRecordingRules, lifecycles))
+	flag.StringVar(&deprecationNoticePath, "emit-deprecation-notice", "", "also write a markdown include listing every deprecated metric with its timeline and reason to this path, e.g. for a migration guide")
+	flag.StringVar(&labelSchemaPath, "emit-label-schema", "", "also write a JSON Schema of the label set of each metric, keyed by its qualified name under $defs and enumerating the known values of its labels, to this path")
+	flag.StringVar(&cheatSheetPath, "emit-cheatsheet", "", "also write a markdown cheat sheet of the metrics of each of the use cases in the config, with the first line of their help, to this path")
+	flag.BoolVar(&opts.emitGraph, "emit-graph", false, "render a section with a Mermaid graph of how the metrics are related by the related metrics in the config")
+	flag.BoolVar(&opts.queryHints, "query-hints", false, "render a suggested PromQL query for each metric based on its type")
+	flag.IntVar(&opts.collapsibleHelp, "collapsible-help", 0, "collapse help text longer than this many characters into a <details> element with a truncated summary, help text isn't collapsed when 0")
+	flag.BoolVar(&opts.escapeMarkdown, "escape-markdown", false, "escape the characters in help text that markdown would interpret as emphasis, e.g. * and _, keeping inline code as written")
+	flag.BoolVar(&opts.valueTypes, "value-types", false, "render the Go type of the value of each metric where it can be inferred from its constructor, e.g. float64")
+	flag.BoolVar(&opts.buckets, "buckets", false, "render the bucket boundaries of each histogram where they can be resolved")
+	flag.BoolVar(&opts.constLabels, "const-labels", false, "render the constant labels of each metric, including those configured in packageConstLabels")
+	flag.StringVar(&glossaryPath, "glossary", "", "path to a YAML file mapping domain terms to urls, e.g. NodeClaim: https://karpenter.sh/docs/concepts/nodeclaims/, whose first occurrence in each help text is linked")
+	flag.StringVar(&titlesPath, "titles", "", "path to a YAML file translating the titles of subsystems, keyed by subsystem name, and of the sections of the document, help text stays as written in source")
+	flag.BoolVar(&opts.sourceSnippets, "show-source-snippet", false, "render the source of the declaration of each metric in a code block under its entry, e.g. for contributor-facing docs")
+	flag.BoolVar(&opts.derivedSeries, "derived-series", false, "render the unit of each series that a histogram or summary is exposed as, e.g. _sum in seconds and _count as a count")
+	flag.StringVar(&opts.cacheDir, "cache-dir", "", "directory to cache the metrics extracted from each package in, skipping unchanged packages on later runs")
+	flag.BoolVar(&opts.splitBySubsystem, "split-by-subsystem", false, "write a document per subsystem into the output path, which must be a directory")
+	flag.BoolVar(&opts.incremental, "incremental", false, "only rewrite output files whose content changed, leaving the others untouched, e.g. with -split-by-subsystem")
+	flag.StringVar((*string)(&opts.reportFormat), "report-format", string(reportFormatText), fmt.Sprintf("how warnings are reported, one of %v, where json writes them to stdout as an array of {severity, metric, rule, message, position}", reportFormats))
+	flag.BoolVar(&opts.failOnWarnings, "fail-on-warnings", false, "fail on every warning, exiting with the number of warnings as the exit code")
+	flag.BoolVar(&opts.strict, "strict", false, "fail on findings that indicate a bug in the metric declarations, e.g. conflicting label sets")
+	flag.BoolVar(&audit, "audit", false, "write a report of every expression that couldn't be resolved, grouped by expression, to the output path rather than the document")
+	flag.BoolVar(&opts.strictPositions, "strict-positions", false, "fail when a metric extracted from the source is without a position, as required to link to or render its source, synthetic metrics are exempt since they aren't declared in the source")
+	flag.BoolVar(&opts.reportUnusedConfig, "report-unused-config", false, "warn about the entries of the config that don't match any extracted metric, package, or feature-gate annotation, e.g. after a rename")
+	flag.BoolVar(&opts.checkRegistration, "check-registration", false, "warn about metrics whose variables are never passed to a registration call in their package, e.g. MustRegister, which is advisory since registration can be indirect")
+	flag.BoolVar(&opts.relaxFatals, "relax-fatals", false, "skip the metrics that are set by values that can't be resolved, e.g. a call to an unknown function, with a warning rather than failing")
+	flag.BoolVar(&opts.detectCrossRootDupes, "detect-cross-root-dupes", false, "flag metrics that are declared beneath more than one of the paths, e.g. by both Karpenter and the provider, which is fatal with -strict")
+	flag.BoolVar(&opts.lintDuplicateHelp, "lint-duplicate-help", false, "flag distinct metrics that share the same help text, which is usually a copy-paste bug")
+	flag.IntVar(&opts.maxNameLength, "max-name-length", 0, "flag metrics whose qualified names are longer than this many characters, names aren't checked when 0")
+	flag.StringVar(&opts.liveScrape, "live-scrape", "", "url of a running metrics endpoint, e.g. http://localhost:8080/metrics, to report metrics that are documented but not exposed and vice versa")
+	flag.Func("namespace-prefix-override", "rewrite the namespace of the documented metrics as old=new, may be repeated", func(s string) error {
+		old, replacement, ok := strings.Cut(s, "=")
+		if !ok || old == "" || replacement == "" {
+			return fmt.Errorf("override must be of the form old=new")
+		}
+		opts.namespaceOverrides[old] = replacement
+		return nil
+	})
+	flag.StringVar(&onlyChanged, "only-changed", "", "report the metrics added, removed, or changed since this git ref in the packages with changed files rather than writing a document, failing on removals. Every path argument is parsed and none is the output path.")
+	flag.StringVar(&diffBaselinePath, "diff", "", "report the metrics added, removed, or changed since a previously generated baseline document rather than writing a document. Every path argument is parsed and none is the output path.")
+	flag.BoolVar(&matrix, "matrix", false, "write a markdown table of which versions document each metric to the output path rather than the document, comparing the -matrix-input baselines with the current metrics")
+	flag.Func("matrix-input", "a baseline document labeled with the version that it documents as version=path for -matrix, in the format of -baseline-format, may be repeated and versions are rendered in order", func(s string) error {
+		input, err := parseMatrixInput(s)
+		if err != nil {
+			return err
+		}
+		matrixInputs = append(matrixInputs, input)
+		return nil
+	})
+	flag.StringVar(&baselineFormatFlag, "baseline-format", string(baselineFormatJSON), fmt.Sprintf("format of the -diff baseline, one of %v, a markdown baseline only records the names and stability levels of metrics", baselineFormats))
+	flag.BoolVar(&opts.noSynthetic, "no-synthetic", false, "only document the metrics extracted from the source, leaving out the status condition, termination, and templated metrics added by convention or config")
+	flag.BoolVar(&scanStructTags, "scan-struct-tags", false, "also document the metrics declared by struct field tags, for frameworks that register the fields of a struct by reflection")
+	flag.StringVar(&structTagKey, "struct-tag-key", "metric", `key of the struct field tags read with -scan-struct-tags, e.g. metric:"name=...,help=..."`)
+	flag.StringVar(&platformFlag, "platform", "", "only document the metrics built for this os/arch, e.g. linux/amd64, rather than those of every platform")
+	flag.BoolVar(&printStats, "stats", false, "write statistics about the run to stderr as a single line of JSON")
+	flag.StringVar(&cpuProfile, "cpuprofile", "", "write a CPU profile of the generation run to this file")
+	flag.StringVar(&memProfile, "memprofile", "", "write a memory profile of the generation run to this file")
+	flag.Usage = usage
 	flag.Parse()
-	if flag.NArg() < 2 {
-		log.Fatalf("Usage: %s path/to/metrics/controller path/to/metrics/controller2 path/to/markdown.md", os.Args[0])
+	if printStats {
+		opts.stats = &generationStats{}
+		defer opts.stats.writeOnExit(os.Stderr, time.Now())()
+	}
+	defer startProfiling(cpuProfile, memProfile)()
+	// The output path is the last argument unless the documents are written into an output directory. The invocation with
+	// only paths and an output path is what hack/docgen.sh relies on, so new behavior must be opt-in through flags that
+	// leave the document generated by that invocation unchanged by default.
+	roots := flag.Args()
+	if outputDir == "" && onlyChanged == "" && diffBaselinePath == "" {
+		if flag.NArg() < 2 {
+			flag.Usage()
+			fatalf("expected at least one path to parse and an output path, got %d arguments", flag.NArg())
+		}
+		roots = flag.Args()[:flag.NArg()-1]
+	} else if flag.NArg() < 1 {
+		flag.Usage()
+		fatalf("expected at least one path to parse, got %d arguments", flag.NArg())
+	}
+	if !slices.Contains(formats, opts.format) {
+		fatalf("invalid -format %q, must be one of %v", opts.format, formats)
+	}
+	outputFormats := []format{opts.format}
+	if formatsFlag != "" {
+		if outputDir == "" {
+			fatalf("-formats is only supported with -output-dir")
+		}
+		outputFormats = lo.Map(strings.Split(formatsFlag, ","), func(f string, _ int) format { return format(strings.TrimSpace(f)) })
+		for _, f := range outputFormats {
+			if !slices.Contains(formats, f) {
+				fatalf("invalid -formats %q, each must be one of %v", f, formats)
+			}
+		}
+	}
+	if opts.splitBySubsystem && opts.format != formatMarkdown {
+		fatalf("-split-by-subsystem is only supported with -format %s", formatMarkdown)
+	}
+	if (deprecationNoticePath != "" || labelSchemaPath != "" || cheatSheetPath != "") && (outputDir != "" || opts.splitBySubsystem) {
+		fatalf("-emit-deprecation-notice, -emit-label-schema, and -emit-cheatsheet aren't supported with -output-dir or -split-by-subsystem")
+	}
+	if opts.splitBySubsystem && outputDir != "" {
+		fatalf("-split-by-subsystem isn't supported with -output-dir, pass the directory as the output path instead")
+	}
+	if !slices.Contains(baselineFormats, baselineFormat(baselineFormatFlag)) {
+		fatalf("invalid -baseline-format %q, must be one of %v", baselineFormatFlag, baselineFormats)
+	}
+	if matrix != (len(matrixInputs) > 0) {
+		fatalf("-matrix requires at least one -matrix-input and -matrix-input is only supported with -matrix")
+	}
+	if !slices.Contains(reportFormats, opts.reportFormat) {
+		fatalf("invalid -report-format %q, must be one of %v", opts.reportFormat, reportFormats)
+	}
+	if !slices.Contains(lifecycles, opts.minStabilityForRules) {
+		fatalf("invalid -min-stability-for-rules %q, must be one of %v", opts.minStabilityForRules, lifecycles)
+	}
+	if !slices.Contains(groupBys, opts.groupBy) {
+		fatalf("invalid -group-by %q, must be one of %v", opts.groupBy, groupBys)
+	}
+	if scanStructTags {
+		opts.structTagKey = structTagKey
+	}
+	if platformFlag != "" {
+		p, err := parsePlatform(platformFlag)
+		if err != nil {
+			fatalf("invalid -platform, %s", err)
+		}
+		opts.platform = p
+	}
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		fatalf("error loading config, %s", err)
+	}
+	opts.config = cfg
+	if glossaryPath != "" {
+		if opts.glossary, err = loadGlossary(glossaryPath); err != nil {
+			fatalf("error loading glossary, %s", err)
+		}
+	}
+	if titlesPath != "" {
+		if opts.titles, err = loadTitles(titlesPath); err != nil {
+			fatalf("error loading titles, %s", err)
+		}
+	}
+	if opts.roots, err = expandRoots(roots); err != nil {
+		fatalf("error expanding paths, %s", err)
+	}
+	if onlyChanged != "" {
+		reportChangedMetrics(opts, onlyChanged)
+		return
+	}
+	if diffBaselinePath != "" {
+		reportBaselineDiff(opts, diffBaselinePath, baselineFormat(baselineFormatFlag))
+		return
+	}
+	if matrix {
+		out, err := generateMatrix(opts, matrixInputs, baselineFormat(baselineFormatFlag))
+		if err != nil {
+			fatalf("error loading baseline, %s", err)
+		}
+		writeOutput(flag.Arg(flag.NArg()-1), out, opts)
+		return
+	}
+	if audit {
+		metrics, _ := getMetrics(opts)
+		out := &bytes.Buffer{}
+		writeAudit(out, metrics)
+		writeOutput(flag.Arg(flag.NArg()-1), out.Bytes(), opts)
+		return
+	}
+	if outputDir != "" {
+		for name, out := range GenerateFormattedMetricsDocs(opts, outputFormats, filenameTemplate) {
+			writeOutput(filepath.Join(outputDir, name), out, opts)
+		}
+		return
+	}
+	output := flag.Arg(flag.NArg() - 1)
+	if opts.splitBySubsystem {
+		for name, out := range GenerateSplitMetricsDocs(opts) {
+			writeOutput(filepath.Join(output, name), out, opts)
+		}
+		return
+	}
+	allMetrics := getCheckedMetrics(opts)
+	out := &bytes.Buffer{}
+	writeFormat(out, opts, allMetrics)
+	writeOutput(output, out.Bytes(), opts)
+	if deprecationNoticePath != "" {
+		notice := &bytes.Buffer{}
+		writeDeprecationNotice(notice, opts, allMetrics)
+		writeOutput(deprecationNoticePath, notice.Bytes(), opts)
+	}
+	if labelSchemaPath != "" {
+		schema := &bytes.Buffer{}
+		writeLabelSchema(schema, opts, allMetrics)
+		writeOutput(labelSchemaPath, schema.Bytes(), opts)
+	}
+	if cheatSheetPath != "" {
+		cheatSheet := &bytes.Buffer{}
+		writeCheatSheet(cheatSheet, opts, allMetrics)
+		writeOutput(cheatSheetPath, cheatSheet.Bytes(), opts)
+	}
+}
+
+// writeOutput writes a generated document to its output file or, when checking, verifies that the file is up to date
+func writeOutput(outputFileName string, out []byte, opts Options) {
+	if opts.check {
+		existing, err := os.ReadFile(outputFileName)
+		if err != nil {
+			fatalf("error reading output file %s, %s", outputFileName, err)
+		}
+		if !upToDate(existing, out, opts.commit != "") {
+			fatalf("%s is out of date, regenerate it with hack/docgen.sh", outputFileName)
+		}
+		log.Println(outputFileName, "is up to date")
+		return
+	}
+	if opts.incremental {
+		if existing, err := os.ReadFile(outputFileName); err == nil && sha256.Sum256(existing) == sha256.Sum256(out) {
+			log.Println(outputFileName, "is unchanged")
+			return
+		}
 	}
+	log.Println("writing output to", outputFileName)
+	if err := os.WriteFile(outputFileName, out, 0644); err != nil {
+		fatalf("error writing output file %s, %s", outputFileName, err)
+	}
+}
+
+// usage documents the positional arguments and flags along with some example invocations
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, `Usage: %[1]s [flags] path/to/parse [path/to/parse...] path/to/output
+
+Parses the metrics declared in the Go packages beneath each path, which may be a glob pattern, and writes their
+documentation to the output path.
+
+Examples:
+  # Document the metrics of a single module
+  %[1]s pkg/ website/content/en/preview/reference/metrics.md
+
+  # Document the metrics declared in a single file
+  %[1]s pkg/controllers/interruption/metrics.go interruption.md
+
+  # Document the metrics of several modules, verifying that the existing document is up to date
+  %[1]s -check pkg/ "${KARPENTER_CORE_DIR}/pkg" website/content/en/preview/reference/metrics.md
+
+  # Render the Prometheus HELP and TYPE metadata of each metric rather than markdown
+  %[1]s -format prometheus-docs pkg/ metrics.txt
+
+  # Write metrics.md, metrics.json, and metrics.csv into docs/ from a single parse
+  %[1]s -output-dir docs/ -formats markdown,json,csv pkg/
+
+  # Report the metrics changed by a pull request, failing if any were removed
+  %[1]s -only-changed origin/main pkg/
+
+Flags:
+`, filepath.Base(os.Args[0]))
+	flag.PrintDefaults()
+}
+
+// expandRoots expands the glob patterns in the path arguments
+func expandRoots(args []string) ([]string, error) {
+	var roots []string
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "*?[") {
+			roots = append(roots, arg)
+			continue
+		}
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, serrors.Wrap(fmt.Errorf("invalid glob pattern, %w", err), "pattern", arg)
+		}
+		if len(matches) == 0 {
+			return nil, serrors.Wrap(fmt.Errorf("glob pattern matched no paths"), "pattern", arg)
+		}
+		roots = append(roots, matches...)
+	}
+	return roots, nil
+}
+
+var commitFooter = regexp.MustCompile(`(?m)^<!-- generated from .* -->\n`)
+
+// upToDate compares an existing document with a freshly generated one
+func upToDate(existing, generated []byte, ignoreCommit bool) bool {
+	if ignoreCommit {
+		existing = commitFooter.ReplaceAll(existing, nil)
+		generated = commitFooter.ReplaceAll(generated, nil)
+	}
+	return bytes.Equal(existing, generated)
+}
+
+// GenerateMetricsDoc parses the metrics declared beneath each of the roots and writes their markdown documentation to w
+func GenerateMetricsDoc(w io.Writer, opts Options) {
+	writeFormat(w, opts, getCheckedMetrics(opts))
+}
+
+// GenerateFormattedMetricsDocs returns a document in each of the formats, keyed by its file name
+func GenerateFormattedMetricsDocs(opts Options, formats []format, template string) map[string][]byte {
+	allMetrics := getCheckedMetrics(opts)
+	docs := map[string][]byte{}
+	for _, f := range formats {
+		out := &bytes.Buffer{}
+		opts.format = f
+		writeFormat(out, opts, allMetrics)
+		docs[strings.ReplaceAll(template, "{ext}", extensions[f])] = out.Bytes()
+	}
+	return docs
+}
+
+// writeFormat renders the metrics in the format of the options
+func writeFormat(w io.Writer, opts Options, allMetrics []metricInfo) {
+	switch opts.format {
+	case formatPrometheusDocs:
+		writePrometheusDocs(w, allMetrics)
+	case formatJSON:
+		writeJSON(w, opts, allMetrics)
+	case formatCSV:
+		writeCSV(w, opts, allMetrics)
+	case formatRecordingRules:
+		writeRecordingRules(w, opts, allMetrics)
+	case formatConfluence:
+		writeConfluence(w, opts, allMetrics)
+	default:
+		writeMarkdown(w, opts, allMetrics)
+		if opts.emitGraph {
+			writeRelationshipGraph(w, opts, allMetrics)
+		}
+		writeCommitFooter(w, opts)
+	}
+}
+
+// GenerateSplitMetricsDocs returns a markdown document for each subsystem, keyed by its file name
+func GenerateSplitMetricsDocs(opts Options) map[string][]byte {
+	allMetrics := getCheckedMetrics(opts)
+	docs := map[string][]byte{}
+	for _, s := range splitBySubsystem(allMetrics) {
+		out := &bytes.Buffer{}
+		writeSectionMarkdown(out, opts, s)
+		writeCommitFooter(out, opts)
+		docs[s.fileName()] = out.Bytes()
+	}
+	return docs
+}
+
+// getCheckedMetrics extracts the metrics to document and reports any warnings about them
+func getCheckedMetrics(opts Options) []metricInfo {
+	allMetrics, warnings := getMetrics(opts)
+	allMetrics, skipped := skipUnsupported(opts, allMetrics)
+	warnings = slices.Concat(warnings, skipped, lintMetrics(opts, allMetrics))
+	var positionless []warning
+	if opts.strictPositions {
+		positionless = lintPositions(allMetrics)
+		warnings = append(warnings, positionless...)
+	}
+	if opts.liveScrape != "" {
+		exposed, err := scrape(opts.liveScrape)
+		if err != nil {
+			fatalf("%s", err)
+		}
+		warnings = append(warnings, lintLiveScrape(allMetrics, exposed)...)
+	}
+	report(opts, warnings)
+	if len(positionless) > 0 {
+		fatalf("found %d metrics extracted from the source without a position, which is required by -strict-positions", len(positionless))
+	}
+	if !opts.includeInternal {
+		allMetrics = lo.Reject(allMetrics, func(m metricInfo, _ int) bool { return m.internal() })
+	}
+	if opts.excludeDeprecated {
+		allMetrics = lo.Reject(allMetrics, func(m metricInfo, _ int) bool { return opts.config.lifecycle(m) == lifecycleDeprecated })
+	}
+	allMetrics, err := filterNamespace(allMetrics, opts.namespace)
+	if err != nil {
+		fatalf("%s", err)
+	}
+	allMetrics, err = filterSubsystems(allMetrics, opts.onlySubsystems, opts.excludeSubsystems)
+	if err != nil {
+		fatalf("%s", err)
+	}
+	return allMetrics
+}
+
+// lintMetrics returns the warnings of the lints that always run and of those that the options enable
+func lintMetrics(opts Options, allMetrics []metricInfo) []warning {
+	warnings := slices.Concat(lint(allMetrics), lintRelated(opts.config, allMetrics), lintRelatedStability(opts.config, allMetrics), lintFeatureGates(opts.config, allMetrics), lintNameLength(allMetrics, opts.maxNameLength),
+		lintUnclassifiedSubsystems(opts.titles, allMetrics), lintUseCases(opts.config, allMetrics))
+	if opts.lintDuplicateHelp {
+		warnings = append(warnings, lintDuplicateHelp(allMetrics)...)
+	}
+	if opts.checkRegistration {
+		warnings = append(warnings, lintRegistration(allMetrics)...)
+	}
+	if opts.reportUnusedConfig {
+		warnings = append(warnings, lintUnusedConfig(opts.config, allMetrics)...)
+	}
+	return warnings
+}
+
+// skipUnsupported fails on metrics set by unsupported expressions, or skips them with -relax-fatals
+func skipUnsupported(opts Options, metrics []metricInfo) ([]metricInfo, []warning) {
+	var warnings []warning
+	kept := lo.Filter(metrics, func(m metricInfo, _ int) bool {
+		e, ok := lo.Find(m.UnresolvedExprs, func(e unresolvedExpr) bool { return e.Unsupported })
+		if !ok {
+			return true
+		}
+		if !opts.relaxFatals {
+			fatalf("unsupported value %s for %s at %s, run with -audit to list every unresolved value", e.Expr, e.Field, e.Position)
+		}
+		warnings = append(warnings, warning{
+			rule:     "skipped",
+			metric:   m.qualifiedName(),
+			position: e.Position,
+			message:  fmt.Sprintf("the metric declared at %s was skipped since its %s is set by the unsupported value %s", m.Position, e.Field, e.Expr),
+		})
+		return false
+	})
+	return kept, warnings
+}
+
+// filterNamespace keeps the metrics of the namespace, or every metric when it's empty
+func filterNamespace(metrics []metricInfo, namespace string) ([]metricInfo, error) {
+	if namespace == "" {
+		return metrics, nil
+	}
+	filtered := lo.Filter(metrics, func(m metricInfo, _ int) bool { return m.Namespace == namespace })
+	if len(filtered) == 0 {
+		namespaces := lo.Uniq(lo.Map(metrics, func(m metricInfo, _ int) string { return m.Namespace }))
+		return nil, serrors.Wrap(fmt.Errorf("no metrics are in the namespace, the metrics are in %v", slices.Sorted(slices.Values(lo.Compact(namespaces)))), "namespace", namespace)
+	}
+	return filtered, nil
+}
+
+// filterSubsystems keeps the metrics of the subsystems in only and drops those in exclude
+func filterSubsystems(metrics []metricInfo, only, exclude []string) ([]metricInfo, error) {
+	if len(only) == 0 && len(exclude) == 0 {
+		return metrics, nil
+	}
+	filtered := lo.Filter(metrics, func(m metricInfo, _ int) bool {
+		return (len(only) == 0 || slices.Contains(only, m.Subsystem)) && !slices.Contains(exclude, m.Subsystem)
+	})
+	if len(filtered) == 0 {
+		return nil, serrors.Wrap(fmt.Errorf("no metrics left to document after filtering subsystems"), "only", only, "exclude", exclude)
+	}
+	return filtered, nil
+}
+
+func writeCommitFooter(w io.Writer, opts Options) {
+	if opts.commit != "" {
+		fmt.Fprintf(w, "<!-- generated from %s -->\n", opts.commit)
+	}
+}
+
+// getMetrics extracts the metrics beneath each of the roots in the order that they're documented
+func getMetrics(opts Options) ([]metricInfo, []warning) {
 	var allMetrics []metricInfo
-	for i := 0; i < flag.NArg()-1; i++ {
-		packages := getPackages(flag.Arg(i))
-		allMetrics = append(allMetrics, getMetricsFromPackages(packages...)...)
+	for _, root := range opts.roots {
+		log.Println("parsing code in", root)
+		allMetrics = append(allMetrics, lo.Map(getRootMetrics(opts, root), func(m metricInfo, _ int) metricInfo {
+			m.Root = root
+			return m
+		})...)
+	}
+	// Declarations that set the namespace and subsystem inconsistently are corrected before anything depends on them
+	for i := range allMetrics {
+		if r, ok := opts.config.Remaps[allMetrics[i].qualifiedName()]; ok {
+			allMetrics[i].remappedFrom = allMetrics[i].qualifiedName()
+			allMetrics[i].Namespace, allMetrics[i].Subsystem = r.Namespace, r.Subsystem
+		}
+	}
+	if !opts.noSynthetic {
+		allMetrics = addPatternBasedMetrics(allMetrics, opts.config)
 	}
 
-	// Dedupe metrics
-	allMetrics = lo.UniqBy(allMetrics, func(m metricInfo) string {
-		return fmt.Sprintf("%s/%s/%s", m.namespace, m.subsystem, m.name)
+	// Dedupe metrics, reporting declarations of the same metric that disagree on its labels
+	warnings := lintLabelConflicts(allMetrics)
+	if opts.detectCrossRootDupes {
+		warnings = append(warnings, lintCrossRootDupes(allMetrics)...)
+	}
+	deduped := lo.UniqBy(allMetrics, func(m metricInfo) string {
+		return fmt.Sprintf("%s/%s/%s", m.Namespace, m.Subsystem, m.Name)
 	})
+	opts.stats.deduped(allMetrics, deduped)
+	allMetrics = deduped
 
 	// Drop some metrics
 	for _, subsystem := range []string{"rest_client", "certwatcher_read", "controller_runtime_webhook"} {
 		allMetrics = lo.Reject(allMetrics, func(m metricInfo, _ int) bool {
-			return strings.HasPrefix(m.name, subsystem)
+			return strings.HasPrefix(m.Name, subsystem)
 		})
 	}
 
-	// Controller Runtime and AWS SDK Go for Prometheus naming is different in that they don't specify a namespace or subsystem
-	// Getting the metrics requires special parsing logic
-	for _, subsystem := range []string{"controller_runtime", "aws_sdk_go", "client_go", "leader_election"} {
+	// Libraries such as Controller Runtime and AWS SDK Go for Prometheus don't specify a namespace or subsystem, so the
+	// configured library prefixes are split from the names of their metrics into subsystems
+	for _, subsystem := range opts.config.Libraries {
 		for i := range allMetrics {
-			if allMetrics[i].subsystem == "" && strings.HasPrefix(allMetrics[i].name, fmt.Sprintf("%s_", subsystem)) {
-				allMetrics[i].subsystem = subsystem
-				allMetrics[i].name = strings.TrimPrefix(allMetrics[i].name, fmt.Sprintf("%s_", subsystem))
+			if allMetrics[i].Subsystem == "" && strings.HasPrefix(allMetrics[i].Name, fmt.Sprintf("%s_", subsystem)) {
+				allMetrics[i].Subsystem = subsystem
+				allMetrics[i].Name = strings.TrimPrefix(allMetrics[i].Name, fmt.Sprintf("%s_", subsystem))
 			}
 		}
 	}
-	sort.Slice(allMetrics, bySubsystem(allMetrics))
-
-	outputFileName := flag.Arg(flag.NArg() - 1)
-	f, err := os.Create(outputFileName)
-	if err != nil {
-		log.Fatalf("error creating output file %s, %s", outputFileName, err)
-	}
-
-	log.Println("writing output to", outputFileName)
-	fmt.Fprintf(f, `---
-title: "Metrics"
-linkTitle: "Metrics"
-weight: 7
-
-description: >
-  Inspect Karpenter Metrics
----
-`)
-	fmt.Fprintf(f, "<!-- this document is generated from hack/docs/metrics_gen/main.go -->\n")
-	fmt.Fprintf(f, "Karpenter makes several metrics available in Prometheus format to allow monitoring cluster provisioning status. "+
-		"These metrics are available by default at `karpenter.kube-system.svc.cluster.local:8080/metrics` configurable via the `METRICS_PORT` environment variable documented [here](../settings)\n")
-	previousSubsystem := ""
-
-	for _, metric := range allMetrics {
-		if metric.subsystem != previousSubsystem {
-			if metric.subsystem != "" {
-				subsystemTitle := strings.Join(lo.Map(strings.Split(metric.subsystem, "_"), func(s string, _ int) string {
-					if s == "sdk" || s == "aws" {
-						return strings.ToUpper(s)
-					} else {
-						return fmt.Sprintf("%s%s", strings.ToUpper(s[0:1]), s[1:])
-					}
-				}), " ")
-				fmt.Fprintf(f, "## %s Metrics\n", subsystemTitle)
-				fmt.Fprintln(f)
-			}
-			previousSubsystem = metric.subsystem
-		}
-		fmt.Fprintf(f, "### `%s`\n", metric.qualifiedName())
-		fmt.Fprintf(f, "%s\n", metric.help)
-		switch {
-		case slices.Contains(stableMetrics, metric.subsystem) || slices.Contains(stableMetrics, metric.qualifiedName()):
-			fmt.Fprintf(f, "- Stability Level: %s\n", "STABLE")
-		case slices.Contains(betaMetrics, metric.subsystem) || slices.Contains(betaMetrics, metric.qualifiedName()):
-			fmt.Fprintf(f, "- Stability Level: %s\n", "BETA")
-		default:
-			fmt.Fprintf(f, "- Stability Level: %s\n", "ALPHA")
+	for i := range allMetrics {
+		// Labels that are injected by a wrapped registerer can't be seen at the constructor and are configured instead
+		if labels, ok := opts.config.PackageConstLabels[allMetrics[i].controller()]; ok && !allMetrics[i].Synthetic {
+			allMetrics[i].ConstLabels = lo.Assign(allMetrics[i].ConstLabels, labels)
+		}
+		if namespace, ok := opts.namespaceOverrides[allMetrics[i].Namespace]; ok {
+			allMetrics[i].sourceNamespace, allMetrics[i].Namespace = allMetrics[i].Namespace, namespace
 		}
-		fmt.Fprintln(f)
 	}
-
+	if opts.transform != nil {
+		allMetrics = opts.transform(allMetrics)
+	}
+	// The sort is stable so that metrics whose qualified names collide keep the order that they were extracted in
+	sort.SliceStable(allMetrics, bySubsystem(allMetrics))
+	if opts.sortLibrariesLast {
+		libraryMetrics, ownMetrics := lo.FilterReject(allMetrics, func(m metricInfo, _ int) bool { return m.library(opts.config) })
+		allMetrics = append(ownMetrics, libraryMetrics...)
+	}
+	opts.stats.found(allMetrics)
+	return allMetrics, warnings
 }
 
-func getPackages(root string) []*ast.Package {
-	var packages []*ast.Package
-	fset := token.NewFileSet()
-
-	// walk our metrics controller directory
-	log.Println("parsing code in", root)
+// getPackageDirs walks our metrics controller directory and returns every directory beneath it
+func getPackageDirs(root string) []string {
+	var dirs []string
 	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if d == nil {
 			return nil
@@ -157,36 +871,94 @@ func getPackages(root string) []*ast.Package {
 		if !d.IsDir() {
 			return nil
 		}
-		// parse the packagers that we find
-		pkgs, err := parser.ParseDir(fset, path, func(info fs.FileInfo) bool {
-			return true
-		}, parser.AllErrors)
-		if err != nil {
-			log.Fatalf("error parsing, %s", err)
-		}
-		for _, pkg := range pkgs {
-			if strings.HasSuffix(pkg.Name, "_test") {
-				continue
-			}
-			packages = append(packages, pkg)
-		}
+		dirs = append(dirs, path)
 		return nil
 	})
+	return dirs
+}
+
+// getPackages parses the non-test packages that are found in dir
+func getPackages(fset *token.FileSet, dir string, p *platform) []*ast.Package {
+	var packages []*ast.Package
+	pkgs, err := parser.ParseDir(fset, dir, func(info fs.FileInfo) bool {
+		return p.matches(dir, info)
+	}, parser.AllErrors|parser.ParseComments)
+	if err != nil {
+		fatalf("error parsing, %s", err)
+	}
+	// Packages and their files are kept in a map so they're ordered by name, and likewise the files of each package are
+	// walked by name in getMetricsFromPackages, so that which declaration of a duplicated metric is documented doesn't
+	// vary between runs
+	for _, name := range slices.Sorted(maps.Keys(pkgs)) {
+		if strings.HasSuffix(name, "_test") {
+			continue
+		}
+		packages = append(packages, pkgs[name])
+	}
 	return packages
 }
 
-func getMetricsFromPackages(packages ...*ast.Package) []metricInfo {
+// getRootMetrics extracts the metrics declared in the packages beneath root or, when root is a file, in that file alone
+func getRootMetrics(opts Options, root string) []metricInfo {
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		if !opts.platform.matches(filepath.Dir(root), info) {
+			return nil
+		}
+		fset := token.NewFileSet()
+		packages := opts.stats.parsed(getFilePackage(fset, root))
+		return append(getMetricsFromPackages(fset, opts.config.constructors(), packages...), getStructTagMetrics(fset, opts.structTagKey, packages...)...)
+	}
+	var metrics []metricInfo
+	for _, dir := range getPackageDirs(root) {
+		metrics = append(metrics, getCachedMetrics(opts.cacheDir, dir, opts.platform, opts.extractionSettings(), func() []metricInfo {
+			fset := token.NewFileSet()
+			packages := opts.stats.parsed(getPackages(fset, dir, opts.platform))
+			return append(getMetricsFromPackages(fset, opts.config.constructors(), packages...), getStructTagMetrics(fset, opts.structTagKey, packages...)...)
+		})...)
+	}
+	return metrics
+}
+
+// extractionSettings are the options that change the extracted metrics, which the cache is keyed by
+func (o Options) extractionSettings() string {
+	settings := o.structTagKey
+	if len(o.config.Wrappers) > 0 {
+		settings += "\n" + string(lo.Must(json.Marshal(o.config.Wrappers)))
+	}
+	return settings
+}
+
+// getFilePackage parses a single file into a package of its own, which is empty when the file is a test
+func getFilePackage(fset *token.FileSet, path string) []*ast.Package {
+	file, err := parser.ParseFile(fset, path, nil, parser.AllErrors|parser.ParseComments)
+	if err != nil {
+		fatalf("error parsing, %s", err)
+	}
+	if strings.HasSuffix(file.Name.Name, "_test") {
+		return nil
+	}
+	return []*ast.Package{{Name: file.Name.Name, Files: map[string]*ast.File{path: file}}}
+}
+
+// getMetricsFromPackages extracts the metrics declared with the constructors, keyed by their package qualified names
+func getMetricsFromPackages(fset *token.FileSet, constructors map[string]constructor, packages ...*ast.Package) []metricInfo {
 	// metrics are all package global variables
 	var allMetrics []metricInfo
 	for _, pkg := range packages {
-		for _, file := range pkg.Files {
+		consts, vars, registered := getPackageConsts(pkg), getPackageVars(pkg), getPackageRegistrations(pkg)
+		for _, path := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[path]
 			for _, decl := range file.Decls {
 				switch v := decl.(type) {
 				case *ast.FuncDecl:
 				// ignore
 				case *ast.GenDecl:
 					if v.Tok == token.VAR {
-						allMetrics = append(allMetrics, handleVariableDeclaration(v)...)
+						allMetrics = append(allMetrics, lo.Map(handleVariableDeclaration(fset, constructors, consts, vars, registered, v), func(m metricInfo, _ int) metricInfo {
+							m.PkgPath = filepath.Dir(path)
+							m.BuildConstraint = buildConstraint(path, file)
+							return m
+						})...)
 					}
 				default:
 
@@ -197,99 +969,336 @@ func getMetricsFromPackages(packages ...*ast.Package) []metricInfo {
 	return allMetrics
 }
 
-func bySubsystem(metrics []metricInfo) func(i int, j int) bool {
-	// Higher ordering comes first. If a value isn't designated here then the subsystem will be given a default of 0.
-	// Metrics without a subsystem come first since there is no designation for the bucket they fall under
-	subSystemSortOrder := map[string]int{
-		"":                 100,
-		"nodepool":         10,
-		"nodeclaims":       9,
-		"nodes":            8,
-		"pods":             7,
-		"status_condition": -1,
-		"workqueue":        -1,
-		"client_go":        -1,
-		"aws_sdk_go":       -1,
-		"leader_election":  -2,
-	}
+// subSystemSortOrder orders the subsystems of the document. Higher ordering comes first. If a value isn't designated
+// here then the subsystem will be given a default of 0. Metrics without a subsystem come first since there is no
+// designation for the bucket they fall under
+var subSystemSortOrder = map[string]int{
+	"":                 100,
+	"nodepool":         10,
+	"nodeclaims":       9,
+	"nodes":            8,
+	"pods":             7,
+	"status_condition": -1,
+	"workqueue":        -1,
+	"client_go":        -1,
+	"aws_sdk_go":       -1,
+	"leader_election":  -2,
+}
 
+func bySubsystem(metrics []metricInfo) func(i int, j int) bool {
 	return func(i, j int) bool {
 		lhs := metrics[i]
 		rhs := metrics[j]
-		if subSystemSortOrder[lhs.subsystem] != subSystemSortOrder[rhs.subsystem] {
-			return subSystemSortOrder[lhs.subsystem] > subSystemSortOrder[rhs.subsystem]
+		if subSystemSortOrder[lhs.Subsystem] != subSystemSortOrder[rhs.Subsystem] {
+			return subSystemSortOrder[lhs.Subsystem] > subSystemSortOrder[rhs.Subsystem]
 		}
 		return lhs.qualifiedName() > rhs.qualifiedName()
 	}
 }
 
-func handleVariableDeclaration(v *ast.GenDecl) []metricInfo {
+// getPackageConsts returns the string constants declared at the package level, keyed by their names
+func getPackageConsts(pkg *ast.Package) map[string]string {
+	consts := map[string]string{}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if i >= len(vs.Values) {
+						break
+					}
+					if lit, ok := vs.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						consts[name.Name] = getBasicLit(lit)
+					}
+				}
+			}
+		}
+	}
+	return consts
+}
+
+// getPackageVars returns the values of the variables declared at the package level, keyed by their names
+func getPackageVars(pkg *ast.Package) map[string]ast.Expr {
+	vars := map[string]ast.Expr{}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				vs := spec.(*ast.ValueSpec)
+				if len(vs.Names) != len(vs.Values) {
+					continue
+				}
+				for i, name := range vs.Names {
+					vars[name.Name] = vs.Values[i]
+				}
+			}
+		}
+	}
+	return vars
+}
+
+func handleVariableDeclaration(fset *token.FileSet, constructors map[string]constructor, consts map[string]string, vars map[string]ast.Expr, registered map[string]struct{}, v *ast.GenDecl) []metricInfo {
 	var promMetrics []metricInfo
 	for _, spec := range v.Specs {
 		vs, ok := spec.(*ast.ValueSpec)
 		if !ok {
 			continue
 		}
-		for _, v := range vs.Values {
-			ce, ok := v.(*ast.CallExpr)
-			if !ok {
-				continue
+		// The doc comment of an ungrouped declaration is attached to the GenDecl rather than the ValueSpec
+		doc := vs.Doc
+		if doc == nil && !v.Lparen.IsValid() {
+			doc = v.Doc
+		}
+		// names are the variables that each constructor call is assigned to
+		names := map[*ast.CallExpr]string{}
+		for i, value := range vs.Values {
+			for _, ce := range getConstructorCalls(value) {
+				names[ce] = vs.Names[i].Name
 			}
+		}
+		for _, ce := range lo.FlatMap(vs.Values, func(v ast.Expr, _ int) []*ast.CallExpr { return getConstructorCalls(v) }) {
 			funcPkg := getFuncPackage(ce.Fun)
-			if funcPkg != "prometheus" {
+			sel, ok := ce.Fun.(*ast.SelectorExpr)
+			if !ok {
 				continue
 			}
-			if len(ce.Args) == 0 {
+			c, ok := constructors[fmt.Sprintf("%s.%s", funcPkg, sel.Sel)]
+			if !ok {
 				continue
 			}
-			arg := ce.Args[0].(*ast.CompositeLit)
-			keyValuePairs := map[string]string{}
-			for _, el := range arg.Elts {
-				kv := el.(*ast.KeyValueExpr)
-				key := fmt.Sprintf("%s", kv.Key)
-				switch key {
-				case "Namespace", "Subsystem", "Name", "Help":
-				default:
-					// skip any keys we don't care about
+			keyValuePairs := lo.Assign(c.fields)
+			var constLabels map[string]string
+			var buckets []float64
+			var unresolved []string
+			var unresolvedExprs []unresolvedExpr
+			var labels []string
+			// resolve sets a field of the metric to the value of its expression, recording the expression when it can't be
+			// resolved
+			resolve := func(key string, expr ast.Expr) {
+				value, resolved, err := getFieldValue(consts, expr)
+				keyValuePairs[key] = value
+				if !resolved {
+					unresolved = append(unresolved, key)
+					unresolvedExprs = append(unresolvedExprs, unresolvedExpr{Field: key, Expr: types.ExprString(expr), Position: fset.Position(expr.Pos()), Unsupported: err != nil})
+				}
+			}
+			if c.args != nil {
+				// Wrappers take the fields of the metric as arguments rather than Opts
+				for _, key := range []string{"Namespace", "Subsystem", "Name", "Help"} {
+					if i, ok := c.args[strings.ToLower(key)]; ok && i < len(ce.Args) {
+						resolve(key, ce.Args[i])
+					}
+				}
+				if i, ok := c.args["labels"]; ok && i < len(ce.Args) {
+					labels = getLabels(consts, ce.Args[i])
+				}
+			} else {
+				if len(ce.Args) <= c.optsIndex {
 					continue
 				}
-				value := ""
-				switch val := kv.Value.(type) {
-				case *ast.BasicLit:
-					value = val.Value
-				case *ast.SelectorExpr:
-					selector := fmt.Sprintf("%s.%s", val.X, val.Sel)
-					if v, err := getIdentMapping(selector); err != nil {
-						log.Fatalf("unsupported selector %s, %s", selector, err)
-					} else {
-						value = v
+				arg, ok := ce.Args[c.optsIndex].(*ast.CompositeLit)
+				if !ok {
+					continue
+				}
+				for _, el := range arg.Elts {
+					kv, ok := el.(*ast.KeyValueExpr)
+					if !ok {
+						continue
 					}
-				case *ast.Ident:
-					if v, err := getIdentMapping(val.String()); err != nil {
-						log.Fatal(err)
-					} else {
-						value = v
+					key := fmt.Sprintf("%s", kv.Key)
+					switch key {
+					case "Namespace", "Subsystem", "Name", "Help":
+					case "ConstLabels":
+						var resolved bool
+						if constLabels, resolved = getConstLabels(consts, kv.Value); !resolved {
+							unresolved = append(unresolved, key)
+							unresolvedExprs = append(unresolvedExprs, unresolvedExpr{Field: key, Expr: types.ExprString(kv.Value), Position: fset.Position(kv.Value.Pos())})
+						}
+						continue
+					case "Buckets":
+						// Buckets are often generated by a function, e.g. prometheus.ExponentialBuckets, in which case they
+						// aren't documented
+						buckets = getBuckets(vars, kv.Value)
+						continue
+					default:
+						// skip any keys we don't care about before looking at their values, since the values of other
+						// fields may be expressions that can't be evaluated
+						continue
 					}
-				case *ast.BinaryExpr:
-					value = getBinaryExpr(val)
-				default:
-					log.Fatalf("unsupported value %T %v", kv.Value, kv.Value)
+					resolve(key, kv.Value)
+				}
+				if c.labelled && len(ce.Args) > c.optsIndex+1 {
+					labels = getLabels(consts, ce.Args[c.optsIndex+1])
 				}
-				keyValuePairs[key] = strings.TrimFunc(value, func(r rune) bool {
-					return r == '"'
-				})
 			}
 			promMetrics = append(promMetrics, metricInfo{
-				namespace: keyValuePairs["Namespace"],
-				subsystem: keyValuePairs["Subsystem"],
-				name:      keyValuePairs["Name"],
-				help:      keyValuePairs["Help"],
+				Namespace:       keyValuePairs["Namespace"],
+				Subsystem:       keyValuePairs["Subsystem"],
+				Name:            keyValuePairs["Name"],
+				Help:            lo.CoalesceOrEmpty(keyValuePairs["Help"], docHelp(doc)),
+				MetricType:      c.metricType,
+				ValueType:       valueTypes[c.metricType],
+				Labels:          labels,
+				ConstLabels:     constLabels,
+				Buckets:         buckets,
+				Unresolved:      unresolved,
+				UnresolvedExprs: unresolvedExprs,
+				Unregistered:    !c.registers && !lo.HasKey(registered, names[ce]),
+				Position:        fset.Position(ce.Pos()),
+				End:             fset.Position(ce.End()),
+				Annotations:     parseAnnotations(doc),
 			})
 		}
 	}
 	return promMetrics
 }
 
+// docHelp returns the doc comment of a declaration as help text, leaving out annotations
+func docHelp(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
+
+// getFieldValue resolves the value of a field of a metric, returning unresolved expressions as written
+func getFieldValue(consts map[string]string, expr ast.Expr) (string, bool, error) {
+	value, resolved := "", true
+	var err error
+	switch val := expr.(type) {
+	case *ast.BasicLit:
+		value = getBasicLit(val)
+	case *ast.SelectorExpr, *ast.Ident:
+		// The constants of the package take precedence over the mapping, which would otherwise shadow a constant of the
+		// same name with the value that it has in another package
+		ident := types.ExprString(val)
+		if v, ok := consts[ident]; ok {
+			value = v
+		} else if v, err := getIdentMapping(ident); err == nil {
+			value = v
+		} else {
+			value, resolved = ident, false
+		}
+	case *ast.BinaryExpr:
+		value, err = getBinaryExpr(val)
+	case *ast.CallExpr:
+		value, err = getCallExpr(val)
+	default:
+		err = fmt.Errorf("unsupported value %T", expr)
+	}
+	if err != nil {
+		return types.ExprString(expr), false, err
+	}
+	return value, resolved, nil
+}
+
+// lazyInitializers wrap the construction of a metric in a function literal, e.g. sync.OnceValue
+var lazyInitializers = []string{"sync.OnceValue", "sync.OnceValues"}
+
+// getConstructorCalls returns the calls that may construct a metric in the value of a variable
+func getConstructorCalls(value ast.Expr) []*ast.CallExpr {
+	switch val := value.(type) {
+	case *ast.CallExpr:
+		if sel, ok := val.Fun.(*ast.SelectorExpr); ok {
+			if receiver, ok := sel.X.(*ast.CallExpr); ok && !slices.Contains(factories, types.ExprString(receiver.Fun)) {
+				return getConstructorCalls(receiver)
+			}
+		}
+		if !slices.Contains(lazyInitializers, types.ExprString(val.Fun)) || len(val.Args) != 1 {
+			return []*ast.CallExpr{val}
+		}
+		fl, ok := val.Args[0].(*ast.FuncLit)
+		if !ok {
+			return nil
+		}
+		var calls []*ast.CallExpr
+		ast.Inspect(fl.Body, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.FuncLit:
+				// The returns of nested function literals don't return from the initializer
+				return false
+			case *ast.ReturnStmt:
+				for _, result := range node.Results {
+					calls = append(calls, getConstructorCalls(result)...)
+				}
+			}
+			return true
+		})
+		return calls
+	case *ast.CompositeLit:
+		if _, ok := val.Type.(*ast.MapType); !ok {
+			return nil
+		}
+		var calls []*ast.CallExpr
+		for _, el := range val.Elts {
+			if kv, ok := el.(*ast.KeyValueExpr); ok {
+				if ce, ok := kv.Value.(*ast.CallExpr); ok {
+					calls = append(calls, ce)
+				}
+			}
+		}
+		return calls
+	}
+	return nil
+}
+
+// getBuckets resolves the buckets of a histogram when they're given as a slice literal of numbers
+func getBuckets(vars map[string]ast.Expr, expr ast.Expr) []float64 {
+	if ident, ok := expr.(*ast.Ident); ok {
+		value, ok := vars[ident.Name]
+		if !ok {
+			return nil
+		}
+		// Only the slice literal of the variable is resolved so that a variable can't refer to itself
+		expr = value
+	}
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+	buckets := []float64{}
+	for _, el := range lit.Elts {
+		value := types.ExprString(el)
+		bucket, err := strconv.ParseFloat(strings.ReplaceAll(value, " ", ""), 64)
+		if err != nil {
+			return nil
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// getLabels resolves the variable labels of a vector metric when they're given as a slice literal
+func getLabels(consts map[string]string, expr ast.Expr) []string {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+	labels := []string{}
+	for _, el := range lit.Elts {
+		switch val := el.(type) {
+		case *ast.BasicLit:
+			labels = append(labels, getBasicLit(val))
+		default:
+			if v, ok := consts[types.ExprString(el)]; ok {
+				labels = append(labels, v)
+			} else if v, err := getIdentMapping(types.ExprString(el)); err == nil {
+				labels = append(labels, v)
+			} else {
+				labels = append(labels, types.ExprString(el))
+			}
+		}
+	}
+	return labels
+}
+
 func getFuncPackage(fun ast.Expr) string {
 	if pexpr, ok := fun.(*ast.ParenExpr); ok {
 		return getFuncPackage(pexpr.X)
@@ -298,6 +1307,10 @@ func getFuncPackage(fun ast.Expr) string {
 		return getFuncPackage(sexpr.X)
 	}
 	if sel, ok := fun.(*ast.SelectorExpr); ok {
+		// The methods of a factory are the constructors of the factory's package
+		if factory, ok := sel.X.(*ast.CallExpr); ok && slices.Contains(factories, types.ExprString(factory.Fun)) {
+			return getFuncPackage(factory.Fun)
+		}
 		return fmt.Sprintf("%s", sel.X)
 	}
 	if ident, ok := fun.(*ast.Ident); ok {
@@ -309,29 +1322,115 @@ func getFuncPackage(fun ast.Expr) string {
 	if _, ok := fun.(*ast.FuncLit); ok {
 		return ""
 	}
-	log.Fatalf("unsupported func expression %T, %v", fun, fun)
+	fatalf("unsupported func expression %T, %v", fun, fun)
 	return ""
 }
 
-func getBinaryExpr(b *ast.BinaryExpr) string {
-	var x, y string
-	switch val := b.X.(type) {
-	case *ast.BasicLit:
-		x = strings.Trim(val.Value, `"`)
-	case *ast.BinaryExpr:
-		x = getBinaryExpr(val)
+// getConstLabels resolves the constant labels of a metric on a best-effort basis
+func getConstLabels(consts map[string]string, expr ast.Expr) (map[string]string, bool) {
+	switch val := expr.(type) {
+	case *ast.CompositeLit:
+		labels, resolved := map[string]string{}, true
+		for _, el := range val.Elts {
+			kv, ok := el.(*ast.KeyValueExpr)
+			if !ok {
+				resolved = false
+				continue
+			}
+			key, keyOk := getConstLabelValue(consts, kv.Key)
+			value, valueOk := getConstLabelValue(consts, kv.Value)
+			if !keyOk || !valueOk {
+				resolved = false
+				continue
+			}
+			labels[key] = value
+		}
+		return labels, resolved
+	case *ast.CallExpr:
+		labels, resolved := map[string]string{}, true
+		for _, arg := range val.Args {
+			merged, ok := getConstLabels(consts, arg)
+			resolved = resolved && ok
+			maps.Copy(labels, merged)
+		}
+		return labels, resolved
 	default:
-		log.Fatalf("unsupported value %T %v", val, val)
+		return nil, false
 	}
-	switch val := b.Y.(type) {
+}
+
+// getConstLabelValue resolves a key or value of a constant label
+func getConstLabelValue(consts map[string]string, expr ast.Expr) (string, bool) {
+	switch val := expr.(type) {
 	case *ast.BasicLit:
-		y = strings.Trim(val.Value, `"`)
-	case *ast.BinaryExpr:
-		y = getBinaryExpr(val)
+		return getBasicLit(val), val.Kind == token.STRING
+	case *ast.SelectorExpr, *ast.Ident:
+		// As with the fields of a metric, the constants of the package take precedence over the mapping
+		ident := types.ExprString(val)
+		if v, ok := consts[ident]; ok {
+			return v, true
+		}
+		v, err := getIdentMapping(ident)
+		return v, err == nil
 	default:
-		log.Fatalf("unsupported value %T %v", val, val)
+		return "", false
+	}
+}
+
+// getBasicLit returns the value of a literal, unquoting string literals
+func getBasicLit(lit *ast.BasicLit) string {
+	if lit.Kind == token.STRING {
+		if value, err := strconv.Unquote(lit.Value); err == nil {
+			return value
+		}
+	}
+	return lit.Value
+}
+
+func getBinaryExpr(b *ast.BinaryExpr) (string, error) {
+	var operands []string
+	for _, operand := range []ast.Expr{b.X, b.Y} {
+		switch val := operand.(type) {
+		case *ast.BasicLit:
+			operands = append(operands, getBasicLit(val))
+		case *ast.BinaryExpr:
+			value, err := getBinaryExpr(val)
+			if err != nil {
+				return "", err
+			}
+			operands = append(operands, value)
+		default:
+			return "", fmt.Errorf("unsupported value %T", val)
+		}
+	}
+	return operands[0] + operands[1], nil
+}
+
+// valueFuncs compute an Opts field from the string literal argument at their index
+var valueFuncs = map[string]int{
+	"metrics.Subsystem": 0,
+}
+
+func getCallExpr(c *ast.CallExpr) (string, error) {
+	var funcName string
+	switch fun := c.Fun.(type) {
+	case *ast.SelectorExpr:
+		funcName = fmt.Sprintf("%s.%s", fun.X, fun.Sel)
+	case *ast.Ident:
+		funcName = fun.String()
+	}
+	argIndex, ok := valueFuncs[funcName]
+	if !ok {
+		return "", fmt.Errorf("unsupported function call %s", funcName)
+	}
+	if argIndex >= len(c.Args) {
+		return "", fmt.Errorf("expected argument %d in call to %s", argIndex, funcName)
+	}
+	lit, ok := c.Args[argIndex].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", fmt.Errorf("expected a string literal argument in call to %s, got %T", funcName, c.Args[argIndex])
 	}
-	return x + y
+	return getBasicLit(lit), nil
 }
 
 // we cannot get the value of an Identifier directly so we map it manually instead
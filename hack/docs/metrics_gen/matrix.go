@@ -0,0 +1,74 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// matrixVersion is the label of the column for the metrics that are extracted from the paths rather than a baseline
+const matrixVersion = "current"
+
+// matrixInput is a baseline labeled with the version that it documents, e.g. v1.1=docs/v1.1/metrics.json
+type matrixInput struct {
+	version string
+	path    string
+}
+
+func parseMatrixInput(value string) (matrixInput, error) {
+	version, path, ok := strings.Cut(value, "=")
+	if !ok || version == "" || path == "" {
+		return matrixInput{}, fmt.Errorf("expected version=path, got %q", value)
+	}
+	return matrixInput{version: version, path: path}, nil
+}
+
+// writeMatrix writes a markdown table of which versions document each metric
+func writeMatrix(w io.Writer, versions []string, documented [][]documentedMetric) {
+	names := lo.Map(documented, func(metrics []documentedMetric, _ int) map[string]struct{} {
+		return lo.SliceToMap(metrics, func(m documentedMetric) (string, struct{}) { return m.Name, struct{}{} })
+	})
+	fmt.Fprintf(w, "| Metric | %s |\n", strings.Join(versions, " | "))
+	fmt.Fprintf(w, "|---|%s\n", strings.Repeat("---|", len(versions)))
+	for _, name := range slices.Sorted(slices.Values(lo.Uniq(lo.FlatMap(names, func(n map[string]struct{}, _ int) []string { return lo.Keys(n) })))) {
+		cells := lo.Map(names, func(n map[string]struct{}, _ int) string { return lo.Ternary(lo.HasKey(n, name), "✓", "✗") })
+		fmt.Fprintf(w, "| `%s` | %s |\n", name, strings.Join(cells, " | "))
+	}
+}
+
+// generateMatrix compares the metrics documented by each of the baselines with those extracted from the paths
+func generateMatrix(opts Options, inputs []matrixInput, f baselineFormat) ([]byte, error) {
+	var versions []string
+	var documented [][]documentedMetric
+	for _, input := range inputs {
+		baseline, err := loadBaseline(input.path, f)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, input.version)
+		documented = append(documented, baseline)
+	}
+	versions = append(versions, matrixVersion)
+	documented = append(documented, documentedMetrics(opts, getCheckedMetrics(opts)))
+	out := &bytes.Buffer{}
+	writeMatrix(out, versions, documented)
+	return out.Bytes(), nil
+}
@@ -0,0 +1,184 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// statusObjects are the kinds that operatorpkg emits status condition and termination metrics for
+var statusObjects = []string{"nodeclaim", "nodepool", "node", "ec2nodeclass"}
+
+// legacyStatusDeprecation and legacyTerminationDeprecation deprecate the legacy operatorpkg metrics
+var (
+	legacyStatusDeprecation = metricConfig{
+		Lifecycle: lifecycleDeprecated,
+		Reason:    "replaced by the status condition metrics of each kind, e.g. operator_nodeclaim_status_condition_count",
+	}
+	legacyTerminationDeprecation = metricConfig{
+		Lifecycle: lifecycleDeprecated,
+		Reason:    "replaced by the termination metrics of each kind, e.g. operator_nodeclaim_termination_duration_seconds",
+	}
+)
+
+// legacyStatusMetrics are the status condition metrics that operatorpkg emits for every kind without naming the kind
+var legacyStatusMetrics = []metricInfo{
+	{
+		Namespace:  "operator",
+		Subsystem:  "status_condition",
+		Name:       "count",
+		Help:       "The number of an condition for a given object, type and status. e.g. Alarm := Available=False > 0",
+		MetricType: metricTypeGauge,
+		builtin:    legacyStatusDeprecation,
+	},
+	{
+		Namespace:  "operator",
+		Subsystem:  "status_condition",
+		Name:       "current_status_seconds",
+		Help:       "The current amount of time in seconds that a status condition has been in a specific state. Alarm := P99(Updated=Unknown) > 5 minutes",
+		MetricType: metricTypeGauge,
+		builtin:    legacyStatusDeprecation,
+	},
+	{
+		Namespace:  "operator",
+		Subsystem:  "status_condition",
+		Name:       "transition_seconds",
+		Help:       "The amount of time a condition was in a given state before transitioning. e.g. Alarm := P99(Updated=False) > 5 minutes",
+		MetricType: metricTypeHistogram,
+		builtin:    legacyStatusDeprecation,
+	},
+	{
+		Namespace:  "operator",
+		Subsystem:  "status_condition",
+		Name:       "transitions_total",
+		Help:       "The count of transitions of a given object, type and status.",
+		MetricType: metricTypeCounter,
+		builtin:    legacyStatusDeprecation,
+	},
+}
+
+// legacyTerminationMetrics are the termination metrics that operatorpkg emits for every kind without naming the kind
+var legacyTerminationMetrics = []metricInfo{
+	{
+		Namespace:  "operator",
+		Subsystem:  "termination",
+		Name:       "current_time_seconds",
+		Help:       "The current amount of time in seconds that an object has been in terminating state.",
+		MetricType: metricTypeGauge,
+		builtin:    legacyTerminationDeprecation,
+	},
+	{
+		Namespace:  "operator",
+		Subsystem:  "termination",
+		Name:       "duration_seconds",
+		Help:       "The amount of time taken by an object to terminate completely.",
+		MetricType: metricTypeHistogram,
+		builtin:    legacyTerminationDeprecation,
+	},
+}
+
+// addPatternBasedMetrics adds the metrics that are generated at runtime unless they're declared
+func addPatternBasedMetrics(metrics []metricInfo, cfg *config) []metricInfo {
+	declared := lo.SliceToMap(metrics, func(m metricInfo) (string, struct{}) { return m.qualifiedName(), struct{}{} })
+	add := func(m metricInfo) {
+		if _, ok := declared[m.qualifiedName()]; ok {
+			return
+		}
+		m.Synthetic = true
+		metrics = append(metrics, m)
+	}
+	for _, object := range statusObjects {
+		lo.ForEach(statusMetrics(object), func(m metricInfo, _ int) { add(m) })
+	}
+	lo.ForEach(legacyStatusMetrics, func(m metricInfo, _ int) { add(m) })
+	lo.ForEach(legacyTerminationMetrics, func(m metricInfo, _ int) { add(m) })
+	for _, t := range cfg.TemplatedMetrics {
+		if !t.Expand {
+			add(metricInfo{
+				Namespace:  t.Namespace,
+				Subsystem:  t.Subsystem,
+				Name:       t.Name,
+				Help:       t.Help,
+				MetricType: t.Type,
+				Dimension:  t.Dimension,
+				Variants:   t.Variants,
+			})
+			continue
+		}
+		for _, variant := range t.Variants {
+			add(metricInfo{
+				Namespace:  t.Namespace,
+				Subsystem:  t.Subsystem,
+				Name:       strings.ReplaceAll(t.Name, t.placeholder(), variant),
+				Help:       strings.ReplaceAll(t.Help, t.placeholder(), variant),
+				MetricType: t.Type,
+			})
+		}
+	}
+	return metrics
+}
+
+// statusMetrics returns the status condition and termination metrics that operatorpkg emits for a kind
+func statusMetrics(object string) []metricInfo {
+	statusSubsystem := fmt.Sprintf("%s_status_condition", object)
+	terminationSubsystem := fmt.Sprintf("%s_termination", object)
+	return []metricInfo{
+		{
+			Namespace:  "operator",
+			Subsystem:  statusSubsystem,
+			Name:       "count",
+			Help:       fmt.Sprintf("The number of a condition for a %s, type and status. Labeled by the name, namespace, type, status, and reason.", object),
+			MetricType: metricTypeGauge,
+		},
+		{
+			Namespace:  "operator",
+			Subsystem:  statusSubsystem,
+			Name:       "current_status_seconds",
+			Help:       fmt.Sprintf("The current amount of time in seconds that a status condition has been in a specific state. Labeled by the name of the %s, namespace, type, status, and reason.", object),
+			MetricType: metricTypeGauge,
+		},
+		{
+			Namespace:  "operator",
+			Subsystem:  statusSubsystem,
+			Name:       "transition_seconds",
+			Help:       fmt.Sprintf("The amount of time a condition was in a given state before transitioning. Labeled by the name of the %s, and the namespace.", object),
+			MetricType: metricTypeHistogram,
+		},
+		{
+			Namespace:  "operator",
+			Subsystem:  statusSubsystem,
+			Name:       "transitions_total",
+			Help:       fmt.Sprintf("The count of transitions of a %s, type and status. Labeled by the type, reason, and status.", object),
+			MetricType: metricTypeCounter,
+		},
+		{
+			Namespace:  "operator",
+			Subsystem:  terminationSubsystem,
+			Name:       "current_time_seconds",
+			Help:       fmt.Sprintf("The current amount of time in seconds that a %s has been in terminating state. Labeled by name, and namespace.", object),
+			MetricType: metricTypeGauge,
+		},
+		{
+			Namespace:  "operator",
+			Subsystem:  terminationSubsystem,
+			Name:       "duration_seconds",
+			Help:       fmt.Sprintf("The amount of time taken by a %s to terminate completely.", object),
+			MetricType: metricTypeHistogram,
+		},
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/awslabs/operatorpkg/serrors"
+	"github.com/samber/lo"
+)
+
+// platform is the target that build constraints are evaluated against when only the metrics built for it are documented
+type platform struct {
+	goos   string
+	goarch string
+}
+
+// parsePlatform parses a platform of the form os/arch, e.g. linux/amd64
+func parsePlatform(s string) (*platform, error) {
+	goos, goarch, ok := strings.Cut(s, "/")
+	if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+		return nil, serrors.Wrap(fmt.Errorf("platform must be of the form os/arch"), "platform", s)
+	}
+	return &platform{goos: goos, goarch: goarch}, nil
+}
+
+// String returns the platform in the form that it was parsed from
+func (p *platform) String() string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", p.goos, p.goarch)
+}
+
+// matches reports whether the file would be built for the platform
+func (p *platform) matches(dir string, info fs.FileInfo) bool {
+	if p == nil {
+		return true
+	}
+	ctx := build.Default
+	ctx.GOOS, ctx.GOARCH = p.goos, p.goarch
+	ctx.BuildTags = nil
+	ok, err := ctx.MatchFile(dir, info.Name())
+	return err == nil && ok
+}
+
+// knownOS and knownArch are the operating systems and architectures that go/build recognizes in file name suffixes
+var (
+	knownOS   = []string{"aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js", "linux", "nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos"}
+	knownArch = []string{"386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64", "mips", "mipsle", "mips64", "mips64le", "mips64p32", "mips64p32le", "ppc", "ppc64", "ppc64le", "riscv", "riscv64", "s390", "s390x", "sparc", "sparc64", "wasm"}
+)
+
+// buildConstraint returns the constraint of a file from its //go:build line and its _os or _arch name suffix
+func buildConstraint(path string, file *ast.File) string {
+	var exprs []constraint.Expr
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if !constraint.IsGoBuild(comment.Text) {
+				continue
+			}
+			if expr, err := constraint.Parse(comment.Text); err == nil {
+				exprs = append(exprs, expr)
+			}
+		}
+	}
+	exprs = append(exprs, fileNameConstraint(path)...)
+	if len(exprs) == 0 {
+		return ""
+	}
+	return lo.Reduce(exprs[1:], func(acc constraint.Expr, expr constraint.Expr, _ int) constraint.Expr {
+		return &constraint.AndExpr{X: acc, Y: expr}
+	}, exprs[0]).String()
+}
+
+// fileNameConstraint returns the constraints implied by the name of a file, following the rules of go/build
+func fileNameConstraint(path string) []constraint.Expr {
+	name := strings.TrimSuffix(filepath.Base(path), ".go")
+	// Everything before the first underscore is ignored, so linux.go isn't constrained
+	_, name, ok := strings.Cut(name, "_")
+	if !ok {
+		return nil
+	}
+	l := strings.Split(strings.TrimSuffix(name, "_test"), "_")
+	n := len(l)
+	if n >= 2 && slices.Contains(knownOS, l[n-2]) && slices.Contains(knownArch, l[n-1]) {
+		return []constraint.Expr{&constraint.TagExpr{Tag: l[n-2]}, &constraint.TagExpr{Tag: l[n-1]}}
+	}
+	if slices.Contains(knownOS, l[n-1]) || slices.Contains(knownArch, l[n-1]) {
+		return []constraint.Expr{&constraint.TagExpr{Tag: l[n-1]}}
+	}
+	return nil
+}
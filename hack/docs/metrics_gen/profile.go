@@ -0,0 +1,76 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+)
+
+// exitHooks are run before the process exits on a fatal error
+var exitHooks []func()
+
+// fatalf runs the exit hooks, e.g. to flush profiles, and then logs the message and exits
+func fatalf(format string, args ...any) {
+	exitf(1, format, args...)
+}
+
+// exitf is fatalf with a specific exit code
+func exitf(code int, format string, args ...any) {
+	for _, hook := range exitHooks {
+		hook()
+	}
+	log.Printf(format, args...)
+	os.Exit(code)
+}
+
+// startProfiling starts the configured profiles and returns a function that writes them
+func startProfiling(cpuProfile, memProfile string) func() {
+	var cpuFile *os.File
+	if cpuProfile != "" {
+		var err error
+		if cpuFile, err = os.Create(cpuProfile); err != nil {
+			fatalf("error creating cpu profile %s, %s", cpuProfile, err)
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			fatalf("error starting cpu profile, %s", err)
+		}
+	}
+	stop := sync.OnceFunc(func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				log.Printf("error closing cpu profile %s, %s", cpuProfile, err)
+			}
+		}
+		if memProfile != "" {
+			f, err := os.Create(memProfile)
+			if err != nil {
+				log.Printf("error creating memory profile %s, %s", memProfile, err)
+				return
+			}
+			defer f.Close()
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				log.Printf("error writing memory profile %s, %s", memProfile, err)
+			}
+		}
+	})
+	exitHooks = append(exitHooks, stop)
+	return stop
+}
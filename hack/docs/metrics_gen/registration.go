@@ -0,0 +1,96 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"slices"
+)
+
+// registrationFuncs are the names of the methods and functions that register collectors
+var registrationFuncs = []string{"MustRegister", "Register"}
+
+// getPackageRegistrations returns the identifiers that are registered anywhere in a package
+func getPackageRegistrations(pkg *ast.Package) map[string]struct{} {
+	registered := map[string]struct{}{}
+	for _, file := range pkg.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.CallExpr:
+				if isRegistrationCall(node) {
+					for _, arg := range node.Args {
+						addIdents(registered, arg)
+					}
+				}
+			case *ast.RangeStmt:
+				if registersElements(node.Body) {
+					addIdents(registered, node.X)
+				}
+			}
+			return true
+		})
+	}
+	return registered
+}
+
+func isRegistrationCall(ce *ast.CallExpr) bool {
+	var name string
+	switch fun := ce.Fun.(type) {
+	case *ast.SelectorExpr:
+		name = fun.Sel.Name
+	case *ast.Ident:
+		name = fun.Name
+	}
+	return slices.Contains(registrationFuncs, name)
+}
+
+// registersElements returns whether the body of a loop contains a registration call
+func registersElements(body *ast.BlockStmt) bool {
+	var found bool
+	ast.Inspect(body, func(n ast.Node) bool {
+		if ce, ok := n.(*ast.CallExpr); ok && isRegistrationCall(ce) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// addIdents adds every identifier within an expression
+func addIdents(idents map[string]struct{}, expr ast.Expr) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			idents[ident.Name] = struct{}{}
+		}
+		return true
+	})
+}
+
+// lintRegistration flags the metrics that are declared but apparently never registered
+func lintRegistration(metrics []metricInfo) []warning {
+	var warnings []warning
+	for _, m := range metrics {
+		if m.Unregistered {
+			warnings = append(warnings, warning{
+				rule:     "registration",
+				metric:   m.qualifiedName(),
+				position: m.Position,
+				message:  fmt.Sprintf("%s at %s is declared but never registered in its package", m.qualifiedName(), m.Position),
+			})
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,533 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+)
+
+type groupBy string
+
+const (
+	groupBySubsystem  groupBy = "subsystem"
+	groupByController groupBy = "controller"
+	groupByNamespace  groupBy = "namespace"
+)
+
+var groupBys = []groupBy{groupBySubsystem, groupByController, groupByNamespace}
+
+// namespaceOrder is the order that namespaces are documented in when grouping by namespace
+var namespaceOrder = []string{"karpenter", "operator"}
+
+type format string
+
+const (
+	formatMarkdown       format = "markdown"
+	formatPrometheusDocs format = "prometheus-docs"
+	formatJSON           format = "json"
+	formatCSV            format = "csv"
+	formatRecordingRules format = "recording-rules"
+	formatConfluence     format = "confluence"
+)
+
+var formats = []format{formatMarkdown, formatPrometheusDocs, formatJSON, formatCSV, formatRecordingRules, formatConfluence}
+
+// extensions are the file extensions of the documents rendered in each format
+var extensions = map[format]string{
+	formatMarkdown:       "md",
+	formatPrometheusDocs: "txt",
+	formatJSON:           "json",
+	formatCSV:            "csv",
+	formatRecordingRules: "yaml",
+	formatConfluence:     "wiki",
+}
+
+func writeMarkdown(w io.Writer, opts Options, allMetrics []metricInfo) {
+	fmt.Fprintf(w, `---
+title: "%[1]s"
+linkTitle: "%[1]s"
+weight: 7
+
+description: >
+  Inspect Karpenter Metrics
+---
+`, opts.titles.section(sectionMetrics))
+	fmt.Fprintf(w, "<!-- this document is generated from hack/docs/metrics_gen/main.go -->\n")
+	fmt.Fprintf(w, "Karpenter makes several metrics available in Prometheus format to allow monitoring cluster provisioning status. "+
+		"These metrics are available by default at `karpenter.kube-system.svc.cluster.local:8080/metrics` configurable via the `METRICS_PORT` environment variable documented [here](../settings)\n")
+	if opts.legend {
+		writeLegend(w, opts)
+	}
+
+	if opts.groupBy == groupByController {
+		// Controllers are documented alphabetically, retaining the subsystem ordering of metrics within each controller
+		sorted := slices.Clone(allMetrics)
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].controller() < sorted[j].controller() })
+		previousController := ""
+		for _, metric := range sorted {
+			if metric.controller() != previousController {
+				fmt.Fprintf(w, "## %s Metrics\n", metric.controller())
+				fmt.Fprintln(w)
+				previousController = metric.controller()
+			}
+			writeMetric(w, opts, metric)
+		}
+		return
+	}
+
+	if opts.groupBy == groupByNamespace {
+		writeNamespaceGroups(w, opts, allMetrics)
+		return
+	}
+
+	var libraryMetrics []metricInfo
+	if opts.groupLibraries {
+		libraryMetrics, allMetrics = lo.FilterReject(allMetrics, func(m metricInfo, _ int) bool { return slices.Contains(opts.config.Libraries, m.Subsystem) })
+	}
+	previousSubsystem := ""
+	for _, metric := range allMetrics {
+		url, collapsed := upstreamDocs(opts, metric.Subsystem)
+		if metric.Subsystem != previousSubsystem {
+			if metric.Subsystem != "" {
+				fmt.Fprintf(w, "## %s\n", opts.titles.subsystem(metric.Subsystem))
+				fmt.Fprintln(w)
+				writeSubsystemDescription(w, opts, metric.Subsystem)
+				if collapsed {
+					fmt.Fprintf(w, "The %s metrics are documented [upstream](%s).\n", subsystemTitle(metric.Subsystem), url)
+					fmt.Fprintln(w)
+				}
+			}
+			previousSubsystem = metric.Subsystem
+		}
+		if !collapsed {
+			writeMetric(w, opts, metric)
+		}
+	}
+	if len(libraryMetrics) > 0 {
+		writeLibraryMetrics(w, opts, libraryMetrics)
+	}
+}
+
+// writeNamespaceGroups documents the metrics of each namespace in its own section
+func writeNamespaceGroups(w io.Writer, opts Options, allMetrics []metricInfo) {
+	byNamespace := lo.GroupBy(allMetrics, func(m metricInfo) string { return m.Namespace })
+	namespaces := lo.Without(lo.Keys(byNamespace), append(namespaceOrder, "")...)
+	sort.Strings(namespaces)
+	for _, namespace := range append(append(slices.Clone(namespaceOrder), namespaces...), "") {
+		if len(byNamespace[namespace]) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "## %s Metrics\n", lo.Ternary(namespace == "", "Library", namespace))
+		fmt.Fprintln(w)
+		previousSubsystem := ""
+		for _, metric := range byNamespace[namespace] {
+			if metric.Subsystem != previousSubsystem {
+				if metric.Subsystem != "" {
+					fmt.Fprintf(w, "### %s\n", opts.titles.subsystem(metric.Subsystem))
+					fmt.Fprintln(w)
+					writeSubsystemDescription(w, opts, metric.Subsystem)
+				}
+				previousSubsystem = metric.Subsystem
+			}
+			writeMetric(w, opts, metric)
+		}
+	}
+}
+
+// writeLibraryMetrics documents the metrics of libraries under a single section
+func writeLibraryMetrics(w io.Writer, opts Options, libraryMetrics []metricInfo) {
+	byLibrary := lo.GroupBy(libraryMetrics, func(m metricInfo) string { return m.Subsystem })
+	fmt.Fprintf(w, "## %s\n", opts.titles.section(sectionLibrary))
+	fmt.Fprintln(w)
+	for _, library := range opts.config.Libraries {
+		if len(byLibrary[library]) == 0 {
+			continue
+		}
+		if url, ok := upstreamDocs(opts, library); ok {
+			fmt.Fprintf(w, "- %s: documented [upstream](%s)\n", subsystemTitle(library), url)
+			continue
+		}
+		fmt.Fprintf(w, "- %s: %s\n", subsystemTitle(library), strings.Join(lo.Map(byLibrary[library], func(m metricInfo, _ int) string {
+			return fmt.Sprintf("`%s`", m.qualifiedName())
+		}), ", "))
+	}
+	fmt.Fprintln(w)
+	for _, library := range opts.config.Libraries {
+		if _, ok := upstreamDocs(opts, library); ok {
+			continue
+		}
+		for _, metric := range byLibrary[library] {
+			writeMetric(w, opts, metric)
+		}
+	}
+}
+
+// section is the documentation of a single subsystem when the output is split into a file per subsystem
+type section struct {
+	subsystem string
+	// weight orders the section in the docs site navigation, following the order of the subsystems in the single page
+	weight  int
+	metrics []metricInfo
+}
+
+func (s section) fileName() string {
+	return fmt.Sprintf("%s.md", lo.Ternary(s.subsystem == "", "general", s.subsystem))
+}
+
+// title returns the title of the section and its title in the docs site navigation
+func (s section) title(t titles) (string, string) {
+	if s.subsystem == "" {
+		return t.section(sectionGeneral), lo.Ternary(lo.HasKey(t.Sections, sectionGeneral), t.section(sectionGeneral), "General")
+	}
+	if title, ok := t.Subsystems[s.subsystem]; ok {
+		return title, title
+	}
+	return t.subsystem(s.subsystem), subsystemTitle(s.subsystem)
+}
+
+// splitBySubsystem divides metrics that are already in document order into a section per subsystem
+func splitBySubsystem(allMetrics []metricInfo) []section {
+	var sections []section
+	for _, metric := range allMetrics {
+		if len(sections) == 0 || sections[len(sections)-1].subsystem != metric.Subsystem {
+			sections = append(sections, section{subsystem: metric.Subsystem, weight: len(sections) + 1})
+		}
+		sections[len(sections)-1].metrics = append(sections[len(sections)-1].metrics, metric)
+	}
+	return sections
+}
+
+func writeSectionMarkdown(w io.Writer, opts Options, s section) {
+	title, linkTitle := s.title(opts.titles)
+	fmt.Fprintf(w, `---
+title: "%s"
+linkTitle: "%s"
+weight: %d
+---
+`, title, linkTitle, s.weight)
+	fmt.Fprintf(w, "<!-- this document is generated from hack/docs/metrics_gen/main.go -->\n")
+	writeSubsystemDescription(w, opts, s.subsystem)
+	for _, metric := range s.metrics {
+		writeMetric(w, opts, metric)
+	}
+}
+
+// upstreamDocs returns the url of the upstream documentation of a collapsed library
+func upstreamDocs(opts Options, subsystem string) (string, bool) {
+	url := opts.config.LibraryDocs[subsystem]
+	return url, opts.collapseLibraries && url != "" && slices.Contains(opts.config.Libraries, subsystem)
+}
+
+// writeSubsystemDescription writes the configured introduction of a subsystem
+func writeSubsystemDescription(w io.Writer, opts Options, subsystem string) {
+	if description := opts.config.SubsystemDescriptions[subsystem]; description != "" {
+		fmt.Fprintf(w, "%s\n", description)
+		fmt.Fprintln(w)
+	}
+}
+
+// relatedNames returns the qualified names of the related metrics of a metric as they're documented
+func (o Options) relatedNames(m metricInfo) []string {
+	return lo.Map(o.config.related(m), func(name string, _ int) string {
+		namespaces := lo.Filter(lo.Keys(o.namespaceOverrides), func(namespace string, _ int) bool { return strings.HasPrefix(name, namespace+"_") })
+		if len(namespaces) == 0 {
+			return name
+		}
+		namespace := lo.MaxBy(namespaces, func(a, b string) bool { return len(a) > len(b) })
+		return o.namespaceOverrides[namespace] + strings.TrimPrefix(name, namespace)
+	})
+}
+
+// writeRelationshipGraph renders the related metrics as the edges of a Mermaid graph
+func writeRelationshipGraph(w io.Writer, opts Options, allMetrics []metricInfo) {
+	documented := lo.SliceToMap(allMetrics, func(m metricInfo) (string, metricInfo) { return m.qualifiedName(), m })
+	var edges []string
+	drawn := map[[2]string]bool{}
+	for _, m := range allMetrics {
+		for _, name := range opts.relatedNames(m) {
+			related, ok := documented[name]
+			if !ok || drawn[[2]string{m.qualifiedName(), name}] {
+				continue
+			}
+			arrow := "-->"
+			if slices.Contains(opts.relatedNames(related), m.qualifiedName()) {
+				arrow = "<-->"
+				drawn[[2]string{name, m.qualifiedName()}] = true
+			}
+			edges = append(edges, fmt.Sprintf("  %s %s %s", m.qualifiedName(), arrow, name))
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "## %s\n", opts.titles.section(sectionRelationships))
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "```mermaid\ngraph LR\n%s\n```\n", strings.Join(edges, "\n"))
+}
+
+// writeLegend explains what each of the stability levels that metrics are documented with means
+func writeLegend(w io.Writer, opts Options) {
+	fmt.Fprintf(w, "## %s\n", opts.titles.section(sectionStabilityLevels))
+	fmt.Fprintln(w)
+	for _, l := range lifecycles {
+		fmt.Fprintf(w, "- %s: %s\n", l.stabilityLevel(), opts.config.legend(l))
+	}
+	fmt.Fprintln(w)
+}
+
+func subsystemTitle(subsystem string) string {
+	return strings.Join(lo.Map(strings.Split(subsystem, "_"), func(s string, _ int) string {
+		if s == "sdk" || s == "aws" {
+			return strings.ToUpper(s)
+		} else {
+			return fmt.Sprintf("%s%s", strings.ToUpper(s[0:1]), s[1:])
+		}
+	}), " ")
+}
+
+// deprecationTimeline describes when a metric was deprecated and when it's planned to be removed
+func deprecationTimeline(since, removal string) string {
+	switch {
+	case since != "" && removal != "":
+		return fmt.Sprintf("Deprecated since %s, removal planned %s", since, removal)
+	case since != "":
+		return fmt.Sprintf("Deprecated since %s", since)
+	case removal != "":
+		return fmt.Sprintf("Removal planned %s", removal)
+	}
+	return ""
+}
+
+// queryHint suggests how a metric is typically queried given its type
+func queryHint(metric metricInfo) string {
+	switch metric.MetricType {
+	case metricTypeCounter:
+		return fmt.Sprintf("rate(%s[5m])", metric.qualifiedName())
+	case metricTypeGauge:
+		return metric.qualifiedName()
+	case metricTypeHistogram:
+		return fmt.Sprintf("histogram_quantile(0.99, rate(%s_bucket[5m]))", metric.qualifiedName())
+	case metricTypeSummary:
+		return fmt.Sprintf("rate(%[1]s_sum[5m]) / rate(%[1]s_count[5m])", metric.qualifiedName())
+	}
+	return ""
+}
+
+// truncate shortens help text to at most limit characters at a word boundary
+func truncate(help string, limit int) string {
+	help = strings.Join(strings.Fields(help), " ")
+	runes := []rune(help)
+	if len(runes) <= limit {
+		return help
+	}
+	truncated := string(runes[:limit])
+	if i := strings.LastIndex(truncated, " "); i > 0 {
+		truncated = truncated[:i]
+	}
+	return strings.TrimRight(truncated, " ,.;:") + "…"
+}
+
+// escapeMarkdown escapes the emphasis characters in help text outside of code spans
+func escapeMarkdown(help string) string {
+	var b strings.Builder
+	for i := 0; i < len(help); i++ {
+		switch help[i] {
+		case '`':
+			if end := strings.IndexByte(help[i+1:], '`'); end >= 0 {
+				b.WriteString(help[i : i+end+2])
+				i += end + 1
+				continue
+			}
+			b.WriteString("\\`")
+		case '*', '_':
+			b.WriteByte('\\')
+			b.WriteByte(help[i])
+		default:
+			b.WriteByte(help[i])
+		}
+	}
+	return b.String()
+}
+
+// baseUnits are the units that are inferred from the suffixes of metric names, following the Prometheus naming conventions
+var baseUnits = []string{"seconds", "bytes", "ratio"}
+
+// unit infers the base unit of a metric from the suffix of its name, which is empty when the name has no unit suffix
+func unit(metric metricInfo) string {
+	unit, _ := lo.Find(baseUnits, func(u string) bool { return strings.HasSuffix(metric.Name, "_"+u) })
+	return unit
+}
+
+// derivedSeries describes the series that a histogram or summary with a unit is exposed as
+func derivedSeries(metric metricInfo) string {
+	u := unit(metric)
+	if u == "" {
+		return ""
+	}
+	switch metric.MetricType {
+	case metricTypeHistogram:
+		return fmt.Sprintf("`%[1]s_bucket` (le in %[2]s), `%[1]s_sum` (%[2]s), `%[1]s_count` (count)", metric.qualifiedName(), u)
+	case metricTypeSummary:
+		return fmt.Sprintf("`%[1]s` (quantiles in %[2]s), `%[1]s_sum` (%[2]s), `%[1]s_count` (count)", metric.qualifiedName(), u)
+	}
+	return ""
+}
+
+// sourceSnippet returns the dedented lines of the source that declare a metric
+func sourceSnippet(metric metricInfo) string {
+	if !metric.Position.IsValid() || !metric.End.IsValid() {
+		return ""
+	}
+	src, err := os.ReadFile(metric.Position.Filename)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(src), "\n")
+	if metric.End.Line > len(lines) {
+		return ""
+	}
+	lines = lines[metric.Position.Line-1 : metric.End.Line]
+	indent := lines[0][:len(lines[0])-len(strings.TrimLeft(lines[0], " \t"))]
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, indent)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func writeMetric(w io.Writer, opts Options, metric metricInfo) {
+	lifecycle := opts.config.lifecycle(metric)
+	heading := fmt.Sprintf("`%s`", metric.qualifiedName())
+	if opts.strikethroughDeprecated && (lifecycle == lifecycleDeprecated || lifecycle == lifecyclePendingRemoval) {
+		heading = fmt.Sprintf("~~%s~~", heading)
+	}
+	// Metrics are nested a level deeper when their subsystem sections are nested within namespace sections
+	fmt.Fprintf(w, "%s %s\n", lo.Ternary(opts.groupBy == groupByNamespace, "####", "###"), heading)
+	help := metric.Help
+	if opts.escapeMarkdown {
+		help = escapeMarkdown(help)
+	}
+	if opts.glossary != nil {
+		help = opts.glossary.link(help)
+	}
+	if opts.collapsibleHelp > 0 && utf8.RuneCountInString(metric.Help) > opts.collapsibleHelp {
+		// The blank lines around the help let it render as markdown within the HTML element
+		fmt.Fprintf(w, "<details>\n<summary>%s</summary>\n\n%s\n\n</details>\n\n", html.EscapeString(truncate(metric.Help, opts.collapsibleHelp)), help)
+	} else {
+		fmt.Fprintf(w, "%s\n", help)
+		// Help spanning multiple lines may hold block elements, e.g. a table, which must be separated from the bullets below
+		if strings.Contains(metric.Help, "\n") {
+			fmt.Fprintln(w)
+		}
+	}
+	if metric.Dimension != "" {
+		fmt.Fprintf(w, "- Variants: one metric per %s, %s\n", metric.Dimension, strings.Join(metric.Variants, ", "))
+	}
+	if metric.BuildConstraint != "" {
+		fmt.Fprintf(w, "- Platform: only built when `%s`\n", metric.BuildConstraint)
+	}
+	if gate := metric.Annotations["feature-gate"]; gate != "" {
+		fmt.Fprintf(w, "- Feature Gate: %s (requires enablement)\n", gate)
+	}
+	if opts.constLabels && len(metric.ConstLabels) > 0 {
+		fmt.Fprintf(w, "- Constant Labels: %s\n", strings.Join(lo.Map(slices.Sorted(maps.Keys(metric.ConstLabels)), func(k string, _ int) string {
+			return fmt.Sprintf("`%s=%s`", k, metric.ConstLabels[k])
+		}), ", "))
+	}
+	for _, label := range metric.Labels {
+		if values := opts.config.labelValues(label); len(values) > 0 && opts.config.regional(label) {
+			fmt.Fprintf(w, "- %s: region-dependent, e.g. %s\n", label, strings.Join(values, ", "))
+		} else if len(values) > 0 {
+			fmt.Fprintf(w, "- %s: %s\n", label, strings.Join(values, ", "))
+		}
+	}
+	if opts.valueTypes && metric.ValueType != "" {
+		fmt.Fprintf(w, "- Value type: %s\n", metric.ValueType)
+	}
+	if opts.buckets && len(metric.Buckets) > 0 {
+		fmt.Fprintf(w, "- Buckets: %s\n", strings.Join(lo.Map(metric.Buckets, func(b float64, _ int) string {
+			return strconv.FormatFloat(b, 'g', -1, 64)
+		}), ", "))
+	}
+	// Malformed ranges are reported by lint and left out of the document
+	if value, ok := metric.Annotations["range"]; ok {
+		if min, max, err := parseRange(value); err == nil {
+			fmt.Fprintf(w, "- Range: %s to %s\n", strconv.FormatFloat(min, 'g', -1, 64), strconv.FormatFloat(max, 'g', -1, 64))
+		}
+	}
+	if value, ok := metric.Annotations["staleness"]; ok {
+		if _, err := parseStaleness(value); err == nil {
+			fmt.Fprintf(w, "- Update interval: ~%s\n", value)
+		}
+	}
+	// Downsampling through a rule is only recommended when one is emitted for the metric
+	if r, ok := emittedRule(opts, metric); ok && metric.Annotations["downsample"] == "recommended" {
+		fmt.Fprintf(w, "- Downsampling: recommended, query the recording rule `%s`\n", r.Record)
+	}
+	if rate, ok := typicalRates[metric.Annotations["typical-rate"]]; ok {
+		fmt.Fprintf(w, "- Typical rate: %s (%s)\n", metric.Annotations["typical-rate"], rate)
+	}
+	if _, ok := metric.Annotations["exemplars"]; ok {
+		fmt.Fprintf(w, "- Exemplars: supported\n")
+	}
+	if series := metric.createdSeries(); series != "" {
+		fmt.Fprintf(w, "- Created-timestamp series: `%s`\n", series)
+	}
+	if hint := queryHint(metric); opts.queryHints && hint != "" {
+		fmt.Fprintf(w, "- Example Query: `%s`\n", hint)
+	}
+	if series := derivedSeries(metric); opts.derivedSeries && series != "" {
+		fmt.Fprintf(w, "- Series: %s\n", series)
+	}
+	if related := opts.relatedNames(metric); len(related) > 0 {
+		fmt.Fprintf(w, "- Related: %s\n", strings.Join(lo.Map(related, func(name string, _ int) string { return fmt.Sprintf("`%s`", name) }), ", "))
+	}
+	fmt.Fprintf(w, "- Stability Level: %s\n", lifecycle.stabilityLevel())
+	if lifecycle == lifecycleDeprecated || lifecycle == lifecyclePendingRemoval {
+		if timeline := deprecationTimeline(opts.config.deprecation(metric)); timeline != "" {
+			fmt.Fprintf(w, "- %s\n", timeline)
+		}
+		if reason := opts.config.deprecationReason(metric); reason != "" {
+			fmt.Fprintf(w, "- Deprecation reason: %s\n", reason)
+		}
+	}
+	if lifecycle == lifecyclePendingRemoval {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "> ⚠️ This metric is pending removal and will be removed in an upcoming release. Migrate any dashboards or alerts that depend on it.\n")
+	}
+	if lifecycle == lifecycleAlpha && opts.alphaBanner {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "> ⚠️ This metric is ALPHA and may change or be removed without notice.\n")
+	}
+	if opts.sourceSnippets {
+		if snippet := sourceSnippet(metric); snippet != "" {
+			fmt.Fprintf(w, "\n```go\n%s\n```\n", snippet)
+		}
+	}
+	// Section headings keep their trailing blank line in the compact form since some renderers require it
+	if !opts.compact {
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// writeCheatSheet writes a markdown cheat sheet with a section for each use case in the config
+func writeCheatSheet(w io.Writer, opts Options, allMetrics []metricInfo) {
+	declared := lo.KeyBy(allMetrics, func(m metricInfo) string { return m.sourceName() })
+	fmt.Fprintf(w, "<!-- this document is generated from hack/docs/metrics_gen/main.go -->\n")
+	fmt.Fprintf(w, "## %s\n", opts.titles.section(sectionCheatSheet))
+	for _, useCase := range slices.Sorted(maps.Keys(opts.config.UseCases)) {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "### %s\n", subsystemTitle(useCase))
+		for _, name := range opts.config.UseCases[useCase] {
+			metric, ok := declared[name]
+			if !ok {
+				continue
+			}
+			help, _, _ := strings.Cut(metric.Help, "\n")
+			fmt.Fprintf(w, "- `%s`: %s\n", metric.qualifiedName(), help)
+		}
+	}
+}
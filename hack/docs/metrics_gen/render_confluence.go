@@ -0,0 +1,56 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// confluenceEscaper escapes the characters in help text that Confluence wiki markup interprets
+var confluenceEscaper = strings.NewReplacer(`{`, `\{`, `}`, `\}`, `[`, `\[`, `]`, `\]`, `*`, `\*`, `_`, `\_`, `|`, `\|`)
+
+// statusColours are the colours of the status macro that the stability level of a metric is rendered with
+var statusColours = map[lifecycle]string{
+	lifecycleStable:         "Green",
+	lifecycleBeta:           "Blue",
+	lifecycleAlpha:          "Yellow",
+	lifecycleDeprecated:     "Red",
+	lifecyclePendingRemoval: "Red",
+}
+
+// writeConfluence writes the metrics as Confluence wiki markup
+func writeConfluence(w io.Writer, opts Options, allMetrics []metricInfo) {
+	fmt.Fprintf(w, "h1. %s\n\n", opts.titles.section(sectionMetrics))
+	fmt.Fprintf(w, "Karpenter makes several metrics available in Prometheus format to allow monitoring cluster provisioning status. "+
+		"These metrics are available by default at {{karpenter.kube-system.svc.cluster.local:8080/metrics}} configurable via the {{METRICS_PORT}} environment variable.\n")
+	previousSubsystem := ""
+	for _, metric := range allMetrics {
+		if metric.Subsystem != previousSubsystem && metric.Subsystem != "" {
+			fmt.Fprintf(w, "\nh2. %s\n", opts.titles.subsystem(metric.Subsystem))
+		}
+		previousSubsystem = metric.Subsystem
+		lifecycle := opts.config.lifecycle(metric)
+		fmt.Fprintf(w, "\nh3. {{%s}}\n", metric.qualifiedName())
+		fmt.Fprintf(w, "%s\n", confluenceEscaper.Replace(metric.Help))
+		fmt.Fprintf(w, "* Stability Level: {status:colour=%s|title=%s}\n", statusColours[lifecycle], lifecycle.stabilityLevel())
+		if opts.sourceSnippets {
+			if snippet := sourceSnippet(metric); snippet != "" {
+				fmt.Fprintf(w, "{code:language=go}\n%s\n{code}\n", snippet)
+			}
+		}
+	}
+}
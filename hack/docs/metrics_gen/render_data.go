@@ -0,0 +1,69 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// documentedMetric is the record of a metric in the data formats, which are meant for tooling rather than readers
+type documentedMetric struct {
+	Name           string   `json:"name"`
+	Type           string   `json:"type,omitempty"`
+	Help           string   `json:"help"`
+	Labels         []string `json:"labels,omitempty"`
+	StabilityLevel string   `json:"stabilityLevel"`
+	// Synthetic metrics are added by convention or config rather than being declared in the source
+	Synthetic bool `json:"synthetic,omitempty"`
+}
+
+func documentedMetrics(opts Options, allMetrics []metricInfo) []documentedMetric {
+	records := make([]documentedMetric, 0, len(allMetrics))
+	for _, m := range allMetrics {
+		records = append(records, documentedMetric{
+			Name:           m.qualifiedName(),
+			Type:           string(m.MetricType),
+			Help:           m.Help,
+			Labels:         m.Labels,
+			StabilityLevel: opts.config.lifecycle(m).stabilityLevel(),
+			Synthetic:      m.Synthetic,
+		})
+	}
+	return records
+}
+
+// writeJSON writes the metrics as an indented JSON array
+func writeJSON(w io.Writer, opts Options, allMetrics []metricInfo) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(documentedMetrics(opts, allMetrics)); err != nil {
+		fatalf("error writing json, %s", err)
+	}
+}
+
+// writeCSV writes the metrics as CSV with a header row, separating the labels of a metric with spaces
+func writeCSV(w io.Writer, opts Options, allMetrics []metricInfo) {
+	cw := csv.NewWriter(w)
+	records := [][]string{{"name", "type", "help", "labels", "stability_level"}}
+	for _, m := range documentedMetrics(opts, allMetrics) {
+		records = append(records, []string{m.Name, m.Type, m.Help, strings.Join(m.Labels, " "), m.StabilityLevel})
+	}
+	if err := cw.WriteAll(records); err != nil {
+		fatalf("error writing csv, %s", err)
+	}
+}
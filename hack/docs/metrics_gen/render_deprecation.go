@@ -0,0 +1,53 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// writeDeprecationNotice writes a markdown include that lists the deprecated metrics
+func writeDeprecationNotice(w io.Writer, opts Options, allMetrics []metricInfo) {
+	fmt.Fprintf(w, "<!-- this document is generated from hack/docs/metrics_gen/main.go -->\n")
+	fmt.Fprintf(w, "## %s\n", opts.titles.section(sectionDeprecated))
+	fmt.Fprintln(w)
+	deprecated := lo.Filter(allMetrics, func(m metricInfo, _ int) bool {
+		return slices.Contains([]lifecycle{lifecycleDeprecated, lifecyclePendingRemoval}, opts.config.lifecycle(m))
+	})
+	if len(deprecated) == 0 {
+		fmt.Fprintf(w, "No metrics are deprecated.\n")
+		return
+	}
+	fmt.Fprintf(w, "The following metrics are deprecated and will be removed. Migrate any dashboards or alerts that depend on them.\n")
+	for _, metric := range deprecated {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "### `%s`\n", metric.qualifiedName())
+		fmt.Fprintf(w, "- Stability Level: %s\n", opts.config.lifecycle(metric).stabilityLevel())
+		if timeline := deprecationTimeline(opts.config.deprecation(metric)); timeline != "" {
+			fmt.Fprintf(w, "- %s\n", timeline)
+		}
+		if reason := opts.config.deprecationReason(metric); reason != "" {
+			fmt.Fprintf(w, "- Deprecation reason: %s\n", reason)
+		}
+		if related := opts.relatedNames(metric); len(related) > 0 {
+			fmt.Fprintf(w, "- Related: %s\n", strings.Join(lo.Map(related, func(name string, _ int) string { return fmt.Sprintf("`%s`", name) }), ", "))
+		}
+	}
+}
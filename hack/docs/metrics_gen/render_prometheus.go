@@ -0,0 +1,39 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// helpEscaper escapes help text the way the Prometheus text exposition format does
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// writePrometheusDocs writes the metrics as Prometheus HELP and TYPE metadata
+func writePrometheusDocs(w io.Writer, allMetrics []metricInfo) {
+	for i, metric := range allMetrics {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		metricType := "untyped"
+		if metric.MetricType != "" {
+			metricType = string(metric.MetricType)
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n", metric.qualifiedName(), helpEscaper.Replace(metric.Help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", metric.qualifiedName(), metricType)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+	"sigs.k8s.io/yaml"
+)
+
+// ruleGroups is a Prometheus rule file, see https://prometheus.io/docs/prometheus/latest/configuration/recording_rules/
+type ruleGroups struct {
+	Groups []ruleGroup `json:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `json:"name"`
+	Rules []rule `json:"rules"`
+}
+
+type rule struct {
+	Record string `json:"record"`
+	Expr   string `json:"expr"`
+}
+
+// recordingRule returns the recording rule for the query hint of a metric
+func recordingRule(metric metricInfo) (rule, bool) {
+	var operation string
+	switch metric.MetricType {
+	case metricTypeCounter:
+		operation = "rate5m"
+	case metricTypeHistogram:
+		operation = "p99_rate5m"
+	case metricTypeSummary:
+		operation = "mean_rate5m"
+	default:
+		return rule{}, false
+	}
+	return rule{Record: fmt.Sprintf("%s:%s", strings.TrimSuffix(metric.qualifiedName(), "_total"), operation), Expr: queryHint(metric)}, true
+}
+
+// emittedRule returns the recording rule that's emitted for a metric that's stable enough
+func emittedRule(opts Options, metric metricInfo) (rule, bool) {
+	minStability := slices.Index(lifecycles, lo.CoalesceOrEmpty(opts.minStabilityForRules, lifecycleBeta))
+	if slices.Index(lifecycles, opts.config.lifecycle(metric)) > minStability {
+		return rule{}, false
+	}
+	return recordingRule(metric)
+}
+
+// writeRecordingRules writes a rule file with the recording rule that's emitted for each metric
+func writeRecordingRules(w io.Writer, opts Options, allMetrics []metricInfo) {
+	group := ruleGroup{Name: "karpenter-metrics", Rules: []rule{}}
+	for _, m := range allMetrics {
+		if r, ok := emittedRule(opts, m); ok {
+			group.Rules = append(group.Rules, r)
+		}
+	}
+	out, err := yaml.Marshal(ruleGroups{Groups: []ruleGroup{group}})
+	if err != nil {
+		fatalf("error writing recording rules, %s", err)
+	}
+	if _, err := w.Write(out); err != nil {
+		fatalf("error writing recording rules, %s", err)
+	}
+}
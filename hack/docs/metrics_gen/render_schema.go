@@ -0,0 +1,69 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/samber/lo"
+)
+
+// labelSchema is a JSON Schema with a definition of the label set of each metric
+type labelSchema struct {
+	Schema string                    `json:"$schema"`
+	Defs   map[string]labelSetSchema `json:"$defs"`
+}
+
+// labelSetSchema describes the labels of a metric
+type labelSetSchema struct {
+	Description          string                         `json:"description,omitempty"`
+	Type                 string                         `json:"type"`
+	Properties           map[string]labelSchemaProperty `json:"properties"`
+	Required             []string                       `json:"required,omitempty"`
+	AdditionalProperties bool                           `json:"additionalProperties"`
+}
+
+// labelSchemaProperty is a label, limited to its known values
+type labelSchemaProperty struct {
+	Type  string   `json:"type"`
+	Enum  []string `json:"enum,omitempty"`
+	Const string   `json:"const,omitempty"`
+}
+
+// writeLabelSchema writes a JSON Schema of the label set of each metric, for consumers that validate label sets
+func writeLabelSchema(w io.Writer, opts Options, allMetrics []metricInfo) {
+	schema := labelSchema{Schema: "https://json-schema.org/draft/2020-12/schema", Defs: map[string]labelSetSchema{}}
+	for _, m := range allMetrics {
+		s := labelSetSchema{Description: m.Help, Type: "object", Properties: map[string]labelSchemaProperty{}}
+		for _, label := range m.Labels {
+			// The examples of region-dependent values aren't every value so they don't limit the label
+			s.Properties[label] = labelSchemaProperty{Type: "string", Enum: lo.Ternary(opts.config.regional(label), nil, opts.config.labelValues(label))}
+		}
+		for label, value := range m.ConstLabels {
+			s.Properties[label] = labelSchemaProperty{Type: "string", Const: value}
+		}
+		s.Required = slices.Sorted(maps.Keys(s.Properties))
+		s.AdditionalProperties = m.Synthetic || slices.Contains(m.Unresolved, "ConstLabels")
+		schema.Defs[m.qualifiedName()] = s
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(schema); err != nil {
+		fatalf("error writing label schema, %s", err)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awslabs/operatorpkg/serrors"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/samber/lo"
+)
+
+// scrapeTimeout bounds the request to the live metrics endpoint
+const scrapeTimeout = 30 * time.Second
+
+// scrape fetches the metric families that are exposed by a running endpoint in the Prometheus text format
+func scrape(url string) ([]string, error) {
+	client := &http.Client{Timeout: scrapeTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, serrors.Wrap(fmt.Errorf("scraping metrics, %w", err), "url", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, serrors.Wrap(fmt.Errorf("scraping metrics, unexpected status %s", resp.Status), "url", url)
+	}
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, serrors.Wrap(fmt.Errorf("parsing scraped metrics, %w", err), "url", url)
+	}
+	names := lo.Keys(families)
+	sort.Strings(names)
+	return names, nil
+}
+
+// lintLiveScrape compares the documented metrics with those exposed by a running endpoint
+func lintLiveScrape(metrics []metricInfo, exposed []string) []warning {
+	documented := map[string]struct{}{}
+	for _, m := range metrics {
+		// A templated family is exposed as a metric per variant rather than by its templated name
+		if m.Dimension != "" {
+			for _, variant := range m.Variants {
+				documented[strings.ReplaceAll(m.qualifiedName(), fmt.Sprintf("{%s}", m.Dimension), variant)] = struct{}{}
+			}
+			continue
+		}
+		documented[m.qualifiedName()] = struct{}{}
+	}
+	exposedNames := lo.SliceToMap(exposed, func(name string) (string, struct{}) { return name, struct{}{} })
+
+	var warnings []warning
+	notExposed := lo.Filter(lo.Keys(documented), func(name string, _ int) bool { return !lo.HasKey(exposedNames, name) })
+	sort.Strings(notExposed)
+	for _, name := range notExposed {
+		warnings = append(warnings, warning{rule: "not-exposed", metric: name, message: fmt.Sprintf("%s is documented but isn't exposed by the endpoint", name), strict: true})
+	}
+	for _, name := range exposed {
+		if !lo.HasKey(documented, name) {
+			warnings = append(warnings, warning{rule: "undocumented", metric: name, message: fmt.Sprintf("%s is exposed by the endpoint but isn't documented", name), strict: true})
+		}
+	}
+	return warnings
+}
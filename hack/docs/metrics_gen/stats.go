@@ -0,0 +1,77 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// generationStats describe the health of a generation run and are no-ops when nil
+type generationStats struct {
+	// PackagesParsed and FilesScanned exclude the packages whose metrics were read from the cache
+	PackagesParsed int `json:"packagesParsed"`
+	FilesScanned   int `json:"filesScanned"`
+	MetricsFound   int `json:"metricsFound"`
+	// DedupeCollisions is the number of declarations that were dropped as duplicates of another
+	DedupeCollisions int `json:"dedupeCollisions"`
+	// UnresolvedExpressions is the number of Opts fields that couldn't be resolved and are documented by identifier
+	UnresolvedExpressions int     `json:"unresolvedExpressions"`
+	DurationSeconds       float64 `json:"durationSeconds"`
+}
+
+func (s *generationStats) parsed(packages []*ast.Package) []*ast.Package {
+	if s != nil {
+		s.PackagesParsed += len(packages)
+		s.FilesScanned += lo.SumBy(packages, func(p *ast.Package) int { return len(p.Files) })
+	}
+	return packages
+}
+
+func (s *generationStats) deduped(before, after []metricInfo) {
+	if s != nil {
+		s.DedupeCollisions += len(before) - len(after)
+	}
+}
+
+func (s *generationStats) found(metrics []metricInfo) {
+	if s != nil {
+		s.MetricsFound = len(metrics)
+		s.UnresolvedExpressions = lo.SumBy(metrics, func(m metricInfo) int { return len(m.Unresolved) })
+	}
+}
+
+// write records how long the run took since start and writes the stats as a single line of JSON
+func (s *generationStats) write(w io.Writer, start time.Time) error {
+	s.DurationSeconds = time.Since(start).Seconds()
+	return json.NewEncoder(w).Encode(s)
+}
+
+// writeOnExit returns an exit hook that writes the stats to w once
+func (s *generationStats) writeOnExit(w io.Writer, start time.Time) func() {
+	write := sync.OnceFunc(func() {
+		if err := s.write(w, start); err != nil {
+			log.Printf("error writing stats, %s", err)
+		}
+	})
+	exitHooks = append(exitHooks, write)
+	return write
+}
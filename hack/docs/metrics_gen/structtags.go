@@ -0,0 +1,95 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// structTagFields are the fields of a struct tag that declares a metric
+var structTagFields = []string{"namespace", "subsystem", "name", "type", "help", "labels"}
+
+// getStructTagMetrics returns the metrics declared by the struct tags of the fields in the packages
+func getStructTagMetrics(fset *token.FileSet, key string, packages ...*ast.Package) []metricInfo {
+	if key == "" {
+		return nil
+	}
+	var metrics []metricInfo
+	for _, pkg := range packages {
+		for _, path := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[path]
+			ast.Inspect(file, func(n ast.Node) bool {
+				field, ok := n.(*ast.Field)
+				if !ok || field.Tag == nil {
+					return true
+				}
+				tag, err := strconv.Unquote(field.Tag.Value)
+				if err != nil {
+					return true
+				}
+				value, ok := reflect.StructTag(tag).Lookup(key)
+				if !ok {
+					return true
+				}
+				// Tags with the key that don't name a metric belong to something else, e.g. an encoding
+				fields := parseStructTag(value)
+				if fields["name"] == "" {
+					return true
+				}
+				metric := metricInfo{
+					Namespace:       fields["namespace"],
+					Subsystem:       fields["subsystem"],
+					Name:            fields["name"],
+					Help:            fields["help"],
+					Labels:          strings.Fields(fields["labels"]),
+					Position:        fset.Position(field.Pos()),
+					End:             fset.Position(field.End()),
+					PkgPath:         filepath.Dir(path),
+					BuildConstraint: buildConstraint(path, file),
+				}
+				if t := metricType(fields["type"]); slices.Contains(metricTypes, t) {
+					metric.MetricType = t
+				}
+				metrics = append(metrics, metric)
+				return true
+			})
+		}
+	}
+	return metrics
+}
+
+// parseStructTag splits the value of a struct tag that declares a metric into its fields
+func parseStructTag(value string) map[string]string {
+	fields := map[string]string{}
+	previous := ""
+	for _, part := range strings.Split(value, ",") {
+		name, v, ok := strings.Cut(part, "=")
+		if ok && slices.Contains(structTagFields, name) {
+			fields[name], previous = v, name
+			continue
+		}
+		if previous != "" {
+			fields[previous] += "," + part
+		}
+	}
+	return fields
+}
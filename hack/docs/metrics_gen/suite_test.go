@@ -0,0 +1,1577 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestMetricsGen(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MetricsGen")
+}
+
+// generate renders the markdown for the metrics declared beneath the roots using the embedded config
+func generate(roots ...string) string {
+	return generateWithOptions(Options{roots: roots})
+}
+
+func generateWithOptions(opts Options) string {
+	out := &bytes.Buffer{}
+	GenerateMetricsDoc(out, withDefaults(opts))
+	return out.String()
+}
+
+// withDefaults fills in the options that a test doesn't set with the defaults that are used by main
+func withDefaults(opts Options) Options {
+	if opts.config == nil {
+		opts.config = lo.Must(loadConfig(""))
+	}
+	return opts
+}
+
+// allMetrics returns the metrics found beneath the roots along with those added synthetically
+func allMetrics(opts Options) []metricInfo {
+	metrics, _ := getMetrics(withDefaults(opts))
+	return metrics
+}
+
+// declaredMetrics returns the metrics found beneath the roots, leaving out those added synthetically
+func declaredMetrics(opts Options) []metricInfo {
+	return lo.Reject(allMetrics(opts), func(m metricInfo, _ int) bool { return m.Synthetic })
+}
+
+var _ = Describe("MetricsGen", func() {
+	Context("Operatorpkg Constructors", func() {
+		DescribeTable("should extract each constructor with a subsystem constant",
+			func(qualifiedName string, metricType metricType, labels []string) {
+				metric, ok := lo.Find(declaredMetrics(Options{roots: []string{"testdata/opmetrics"}}), func(m metricInfo) bool { return m.qualifiedName() == qualifiedName })
+				Expect(ok).To(BeTrue())
+				Expect(metric.MetricType).To(Equal(metricType))
+				Expect(metric.Labels).To(Equal(labels))
+				Expect(metric.Unresolved).To(BeEmpty())
+			},
+			Entry("NewPrometheusGauge", "karpenter_queue_depth", metricTypeGauge, []string{"queue"}),
+			Entry("NewPrometheusCounter", "karpenter_batcher_batches_flushed_total", metricTypeCounter, []string{"batcher"}),
+			Entry("NewPrometheusHistogram", "karpenter_registry_request_duration_seconds", metricTypeHistogram, []string{"operation"}),
+			Entry("NewPrometheusSummary", "karpenter_lease_renewal_duration_seconds", metricTypeSummary, []string{}),
+		)
+		It("should cover every operatorpkg constructor", func() {
+			Expect(lo.Filter(lo.Keys(constructors), func(name string, _ int) bool { return strings.HasPrefix(name, "opmetrics.") })).
+				To(ConsistOf("opmetrics.NewPrometheusGauge", "opmetrics.NewPrometheusCounter", "opmetrics.NewPrometheusHistogram", "opmetrics.NewPrometheusSummary"))
+		})
+	})
+	Context("Extraction", func() {
+		It("should resolve a subsystem computed by a registered helper function", func() {
+			Expect(generate("testdata/subsystemfunc")).To(ContainSubstring("## Nodeclaims Metrics\n\n### `karpenter_nodeclaims_launched_total`\nNumber of nodeclaims launched in total by Karpenter.\n"))
+		})
+		It("should fall back to the doc comment of a declaration without help", func() {
+			out := generate("testdata/dochelp")
+			Expect(out).To(ContainSubstring("### `karpenter_pods_preempted_total`\n" +
+				"Number of pods that were preempted to make room for pods of a higher priority, labeled by the priority class of the preempting pod.\n" +
+				"- Typical rate: low"))
+			Expect(out).To(ContainSubstring("### `karpenter_pods_bound_total`\nNumber of pods bound to nodes in total.\n"))
+		})
+		It("should unquote raw string literals", func() {
+			Expect(generate("testdata/rawstrings")).To(ContainSubstring("### `karpenter_nodeclaims_garbage_collected_total`\n" +
+				"Number of nodeclaims garbage collected in total after their instance was \"orphaned\"\n"))
+		})
+		It("should unquote labels in either quoting style", func() {
+			metric, ok := lo.Find(declaredMetrics(Options{roots: []string{"testdata/rawstrings"}}), func(m metricInfo) bool { return m.Name == "disrupted_total" })
+			Expect(ok).To(BeTrue())
+			Expect(metric.Labels).To(Equal([]string{"nodepool", "capacity_type"}))
+			Expect(generate("testdata/rawstrings")).To(ContainSubstring("- capacity_type: spot, on-demand, reserved\n"))
+		})
+		It("should render help spanning multiple lines faithfully", func() {
+			Expect(generate("testdata/multiline")).To(ContainSubstring("### `karpenter_pods_evictions_total`\n" +
+				"Number of pods evicted in total, labeled by reason.\n" +
+				"| Reason | Meaning |\n" +
+				"|---|---|\n" +
+				"| drift | The node drifted from its NodePool. |\n" +
+				"| expiration | The node \"expired\". |\n\n" +
+				"- Stability Level: ALPHA\n"))
+		})
+		It("should resolve identifiers from the constants of the package", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/unmapped"}})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ContainElement("karpenter_reconciler_errors_total"))
+		})
+		It("should document a metric with an unmapped namespace by its identifier and warn", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/unmapped"}})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ContainElement("telemetry.Namespace_reconciler_reconciles_total"))
+			warnings := lintUnresolved(metrics)
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].String()).To(Equal("[unresolved] telemetry.Namespace_reconciler_reconciles_total at testdata/unmapped/metrics.go:26:15 is documented with unresolved identifiers for Namespace"))
+		})
+		It("should audit the unresolved expressions grouped by expression and ordered by occurrences", func() {
+			out := &bytes.Buffer{}
+			writeAudit(out, declaredMetrics(Options{roots: []string{"testdata/audit"}}))
+			Expect(out.String()).To(Equal("telemetry.Namespace (2 occurrences)\n" +
+				"  testdata/audit/metrics.go:26:15 Namespace of telemetry.Namespace_sync_runs_total\n" +
+				"  testdata/audit/metrics.go:34:15 Namespace of telemetry.Namespace_sync_telemetry.ErrorsName\n" +
+				"telemetry.ErrorsName (1 occurrence)\n" +
+				"  testdata/audit/metrics.go:36:15 Name of telemetry.Namespace_sync_telemetry.ErrorsName\n"))
+		})
+		It("should report unsupported expressions as written rather than failing", func() {
+			value, resolved, err := getFieldValue(nil, lo.Must(parser.ParseExpr(`fmt.Sprintf("%s_total", name)`)))
+			Expect(err).To(MatchError("unsupported function call fmt.Sprintf"))
+			Expect(resolved).To(BeFalse())
+			Expect(value).To(Equal(`fmt.Sprintf("%s_total", name)`))
+			out := &bytes.Buffer{}
+			writeAudit(out, []metricInfo{{Name: value, UnresolvedExprs: []unresolvedExpr{{Field: "Name", Expr: value, Unsupported: true}}}})
+			Expect(out.String()).To(HavePrefix(`fmt.Sprintf("%s_total", name) (1 occurrence, unsupported)`))
+		})
+		It("should skip a metric set by an unsupported value with a warning when fatals are relaxed", func() {
+			// The fixture is written outside of testdata since generating its metrics fails without -relax-fatals
+			dir := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "metrics.go"), []byte(`package unsupported
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	Retries = prometheus.NewCounter(prometheus.CounterOpts{Namespace: "karpenter", Subsystem: "queue", Name: "retries_total", Help: "Number of retries in total."})
+	Dropped = prometheus.NewCounter(prometheus.CounterOpts{Namespace: "karpenter", Subsystem: "queue", Name: fmt.Sprintf("%s_total", "dropped"), Help: "Number of items dropped in total."})
+)
+`), 0644)).To(Succeed())
+			metrics, warnings := skipUnsupported(Options{relaxFatals: true}, declaredMetrics(Options{roots: []string{dir}}))
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(Equal([]string{"karpenter_queue_retries_total"}))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("skipped"))
+			Expect(warnings[0].message).To(Equal(fmt.Sprintf(`the metric declared at %[1]s:7:12 was skipped since its Name is set by the unsupported value fmt.Sprintf("%%s_total", "dropped")`, filepath.Join(dir, "metrics.go"))))
+			Expect(generateWithOptions(Options{roots: []string{dir}, relaxFatals: true})).To(ContainSubstring("### `karpenter_queue_retries_total`\n"))
+		})
+		It("should ignore the values of Opts fields that aren't documented, in any order", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/ignoredfields"}})
+			Expect(metrics).To(HaveLen(1))
+			Expect(metrics[0].qualifiedName()).To(Equal("karpenter_scheduler_simulation_duration_seconds"))
+			Expect(metrics[0].Help).To(Equal("Duration of scheduling simulations in seconds."))
+			Expect(metrics[0].MetricType).To(Equal(metricTypeHistogram))
+		})
+		It("should extract the Opts of func based collectors", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/funcs"}})
+			Expect(metrics).To(HaveLen(2))
+			Expect(metrics[0].qualifiedName()).To(Equal("karpenter_runtime_workers_started_total"))
+			Expect(metrics[0].Help).To(Equal("Number of workers started in total."))
+			Expect(metrics[0].MetricType).To(Equal(metricTypeCounter))
+			Expect(metrics[1].qualifiedName()).To(Equal("karpenter_runtime_goroutines"))
+			Expect(metrics[1].Help).To(Equal("Number of goroutines that currently exist."))
+			Expect(metrics[1].MetricType).To(Equal(metricTypeGauge))
+		})
+		It("should extract the metrics constructed by lazy initializers", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/lazyinit"}})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ConsistOf("karpenter_cluster_snapshots_taken_total", "karpenter_cluster_snapshot_size"))
+			size, _ := lo.Find(metrics, func(m metricInfo) bool { return m.Name == "snapshot_size" })
+			Expect(size.MetricType).To(Equal(metricTypeGauge))
+			Expect(size.Labels).To(Equal([]string{"kind"}))
+		})
+		It("should extract the metrics constructed by promauto directly and through a factory", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/promauto"}})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ConsistOf("karpenter_cache_hits_total", "karpenter_cache_entries"))
+			entries, _ := lo.Find(metrics, func(m metricInfo) bool { return m.Name == "entries" })
+			Expect(entries.MetricType).To(Equal(metricTypeGauge))
+			Expect(entries.Labels).To(Equal([]string{"kind"}))
+			Expect(lo.EveryBy(metrics, func(m metricInfo) bool { return !m.Unregistered })).To(BeTrue())
+		})
+		It("should extract the metrics whose constructors have methods chained onto them", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/curried"}})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ConsistOf("karpenter_interruption_received_messages_total", "karpenter_interruption_deleted_messages_total"))
+			received, _ := lo.Find(metrics, func(m metricInfo) bool { return m.Name == "received_messages_total" })
+			Expect(received.MetricType).To(Equal(metricTypeCounter))
+			Expect(received.Labels).To(Equal([]string{"queue", "message_type"}))
+		})
+		It("should extract the metrics registered by the values of a map literal", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/registrationmap"}})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ConsistOf("karpenter_batcher_queued_items", "karpenter_batcher_flushes_total"))
+			flushes, _ := lo.Find(metrics, func(m metricInfo) bool { return m.Name == "flushes_total" })
+			Expect(flushes.MetricType).To(Equal(metricTypeCounter))
+			Expect(flushes.Labels).To(Equal([]string{"reason"}))
+		})
+	})
+	Context("No Synthetic", func() {
+		It("should only document the metrics extracted from the source", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, noSynthetic: true})
+			Expect(out).ToNot(ContainSubstring("status_condition"))
+			Expect(out).ToNot(ContainSubstring("_termination_"))
+			Expect(out).To(ContainSubstring("### `karpenter_nodeclaims_launched_total`\n"))
+			Expect(lo.ContainsBy(allMetrics(Options{roots: []string{"testdata/controllers"}, noSynthetic: true}), func(m metricInfo) bool { return m.Synthetic })).To(BeFalse())
+		})
+		It("should document the synthetic status condition metrics by default", func() {
+			Expect(generate("testdata/controllers")).To(ContainSubstring("### `operator_nodepool_status_condition_count`\n"))
+		})
+	})
+	Context("Struct Tags", func() {
+		It("should document the metrics declared by struct field tags when scanning them", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/structtags"}, structTagKey: "metric"})
+			Expect(metrics).To(HaveLen(2))
+			scheduled, _ := lo.Find(metrics, func(m metricInfo) bool { return m.Name == "pods_scheduled_total" })
+			Expect(scheduled.qualifiedName()).To(Equal("karpenter_provisioner_pods_scheduled_total"))
+			Expect(scheduled.Help).To(Equal("Number of pods scheduled in total, by nodepool."))
+			Expect(scheduled.MetricType).To(Equal(metricTypeCounter))
+			Expect(scheduled.Labels).To(Equal([]string{"nodepool", "zone"}))
+			Expect(scheduled.Position.String()).To(Equal("testdata/structtags/metrics.go:23:2"))
+		})
+		It("should read the configured struct tag key", func() {
+			Expect(declaredMetrics(Options{roots: []string{"testdata/structtags"}, structTagKey: "json"})).To(BeEmpty())
+		})
+		It("should not read struct tags by default", func() {
+			Expect(declaredMetrics(Options{roots: []string{"testdata/structtags"}})).To(BeEmpty())
+		})
+	})
+	Context("Pattern Based Metrics", func() {
+		It("should add the status condition metrics of each kind", func() {
+			metrics := allMetrics(Options{})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ContainElements(
+				"operator_nodeclaim_status_condition_count",
+				"operator_ec2nodeclass_termination_duration_seconds",
+				"operator_status_condition_transitions_total",
+			))
+			Expect(metrics).To(HaveEach(HaveField("Synthetic", BeTrue())))
+		})
+		It("should prefer a statically declared metric over a synthetic one with the same name", func() {
+			metrics := lo.Filter(allMetrics(Options{roots: []string{"testdata/statuscondition"}}), func(m metricInfo, _ int) bool {
+				return m.qualifiedName() == "operator_nodeclaim_status_condition_count"
+			})
+			Expect(metrics).To(HaveLen(1))
+			Expect(metrics[0].Help).To(Equal("The number of conditions for a nodeclaim, declared statically."))
+			Expect(metrics[0].Synthetic).To(BeFalse())
+		})
+	})
+	Context("Labels", func() {
+		It("should extract the labels of vector metrics", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/conflicts/scheduler"}})
+			Expect(metrics).To(HaveLen(1))
+			Expect(metrics[0].Labels).To(Equal([]string{"nodepool", "zone"}))
+			Expect(metrics[0].Position.String()).To(Equal("testdata/conflicts/scheduler/metrics.go:23:21"))
+		})
+		It("should report a conflict between declarations of the same metric with different labels", func() {
+			metrics, warnings := getMetrics(withDefaults(Options{roots: []string{"testdata/conflicts"}}))
+			Expect(lo.Filter(metrics, func(m metricInfo, _ int) bool { return m.qualifiedName() == "karpenter_scheduler_pods_scheduled_total" })).To(HaveLen(1))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("label-conflict"))
+			Expect(warnings[0].strict).To(BeTrue())
+			Expect(warnings[0].message).To(Equal("karpenter_scheduler_pods_scheduled_total is declared with labels [nodepool] at testdata/conflicts/provisioner/metrics.go:23:21 " +
+				"and with labels [nodepool, zone] at testdata/conflicts/scheduler/metrics.go:23:21"))
+		})
+	})
+	Context("Constant Labels", func() {
+		It("should render the constant labels of a map literal", func() {
+			Expect(generateWithOptions(Options{roots: []string{"testdata/constlabels"}, constLabels: true})).To(ContainSubstring("### `karpenter_batcher_batch_duration_seconds`\nDuration of batches in seconds.\n" +
+				"- Constant Labels: `queue=provisioning`\n"))
+			Expect(generate("testdata/constlabels")).ToNot(ContainSubstring("- Constant Labels:"))
+		})
+		It("should merge the literal arguments of a helper, noting the arguments that can't be resolved", func() {
+			metric, ok := lo.Find(declaredMetrics(Options{roots: []string{"testdata/constlabels"}}), func(m metricInfo) bool { return m.Name == "batch_size" })
+			Expect(ok).To(BeTrue())
+			Expect(metric.ConstLabels).To(Equal(map[string]string{"queue": "disruption", "priority": "high"}))
+			Expect(metric.Unresolved).To(Equal([]string{"ConstLabels"}))
+			Expect(generateWithOptions(Options{roots: []string{"testdata/constlabels"}, constLabels: true})).To(ContainSubstring("- Constant Labels: `priority=high`, `queue=disruption`\n"))
+		})
+		It("should prefer the constants of the package over the mapping", func() {
+			metric, ok := lo.Find(declaredMetrics(Options{roots: []string{"testdata/constlabels"}}), func(m metricInfo) bool { return m.Name == "batch_retries" })
+			Expect(ok).To(BeTrue())
+			Expect(metric.ConstLabels).To(Equal(map[string]string{"counter": "attempt"}))
+			Expect(metric.Labels).To(Equal([]string{"attempt"}))
+		})
+	})
+	Context("Buckets", func() {
+		It("should resolve buckets shared through a variable declared in another file of the package", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/buckets"}})
+			for _, name := range []string{"reconcile_duration_seconds", "request_duration_seconds"} {
+				metric, ok := lo.Find(metrics, func(m metricInfo) bool { return m.Name == name })
+				Expect(ok).To(BeTrue())
+				Expect(metric.Buckets).To(Equal([]float64{0.005, 0.05, 0.5, 1, 2.5, 10}))
+			}
+			Expect(generateWithOptions(Options{roots: []string{"testdata/buckets"}, buckets: true})).To(ContainSubstring("### `karpenter_buckets_reconcile_duration_seconds`\nDuration of reconciles in seconds.\n" +
+				"- Buckets: 0.005, 0.05, 0.5, 1, 2.5, 10\n"))
+			Expect(generate("testdata/buckets")).ToNot(ContainSubstring("- Buckets:"))
+		})
+		It("should leave out buckets that are generated", func() {
+			metric, ok := lo.Find(declaredMetrics(Options{roots: []string{"testdata/buckets"}}), func(m metricInfo) bool { return m.Name == "batch_size" })
+			Expect(ok).To(BeTrue())
+			Expect(metric.Buckets).To(BeNil())
+		})
+	})
+	Context("Package Constant Labels", func() {
+		It("should render the constant labels injected into the metrics of a package", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/package_labels.yaml")), constLabels: true})
+			Expect(out).To(ContainSubstring("### `karpenter_nodeclaims_launched_total`\nNumber of nodeclaims launched in total by Karpenter.\n- Constant Labels: `registry=wrapped`\n"))
+			Expect(out).To(ContainSubstring("### `karpenter_nodepools_ready`\nNumber of nodepools that are ready.\n- Stability Level: ALPHA\n"))
+		})
+		It("should merge the injected labels with the declared constant labels", func() {
+			cfg := &config{PackageConstLabels: map[string]map[string]string{"constlabels": {"registry": "wrapped"}}}
+			metrics := declaredMetrics(Options{roots: []string{"testdata"}, config: cfg})
+			metric, ok := lo.Find(metrics, func(m metricInfo) bool { return m.Name == "batch_duration_seconds" })
+			Expect(ok).To(BeTrue())
+			Expect(metric.ConstLabels).To(Equal(map[string]string{"queue": "provisioning", "registry": "wrapped"}))
+		})
+	})
+	Context("Label Values", func() {
+		It("should render the configured values of the labels that a metric declares", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/createdtimestamps"}, config: lo.Must(loadConfig("testdata/config/label_values.yaml"))})
+			Expect(out).To(ContainSubstring("### `karpenter_instances_launched_total`\nNumber of instances launched in total.\n- capacity_type: spot, on-demand\n"))
+			Expect(out).ToNot(ContainSubstring("- zone:"))
+		})
+		It("should enumerate the values of well-known labels by default", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/resourcetypes"}})
+			Expect(out).To(ContainSubstring("### `karpenter_nodes_total_pod_requests`\n" +
+				"Node total pod requests are the resources requested by pods bound to nodes.\n" +
+				"- resource_type: cpu, memory, pods, ephemeral-storage\n"))
+		})
+		It("should document examples of the values of region-dependent labels by default", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/resourcetypes"}})
+			Expect(out).To(ContainSubstring("- capacity_type: spot, on-demand, reserved\n- zone: region-dependent, e.g. us-west-2a, us-west-2b, us-west-2c\n"))
+			Expect(generateWithOptions(Options{roots: []string{"testdata/resourcetypes"}, config: lo.Must(loadConfig("testdata/config/label_values.yaml"))})).
+				To(ContainSubstring("- zone: us-west-2a, us-west-2b\n"))
+
+			opts := withDefaults(Options{roots: []string{"testdata/resourcetypes"}})
+			schema := &bytes.Buffer{}
+			writeLabelSchema(schema, opts, getCheckedMetrics(opts))
+			Expect(schema.String()).To(ContainSubstring("\"zone\": {\n          \"type\": \"string\"\n        }"))
+		})
+		It("should prefer the values of a label in the config over its default values", func() {
+			cfg := &config{LabelValues: map[string][]string{"resource_type": {"cpu", "memory"}}}
+			out := generateWithOptions(Options{roots: []string{"testdata/resourcetypes"}, config: cfg})
+			Expect(out).To(ContainSubstring("- resource_type: cpu, memory\n"))
+			path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+			Expect(os.WriteFile(path, []byte("labelValues:\n  resource_type: []\n"), 0o600)).To(Succeed())
+			Expect(generateWithOptions(Options{roots: []string{"testdata/resourcetypes"}, config: lo.Must(loadConfig(path))})).ToNot(ContainSubstring("- resource_type:"))
+		})
+	})
+	Context("Positional Arguments", func() {
+		// The invocation with only paths and an output path is relied on by hack/docgen.sh, so flags must not change the
+		// document that it generates by default. Regenerate the golden file only for an intended change to the default.
+		It("should document the metrics beneath the paths to the output path without any flags", func() {
+			dir := GinkgoT().TempDir()
+			build := exec.Command("go", "build", "-o", filepath.Join(dir, "metrics_gen"), ".")
+			Expect(build.Run()).To(Succeed())
+			output := filepath.Join(dir, "metrics.md")
+			Expect(exec.Command(filepath.Join(dir, "metrics_gen"), "testdata/controllers", output).Run()).To(Succeed())
+			Expect(string(lo.Must(os.ReadFile(output)))).To(Equal(string(lo.Must(os.ReadFile("testdata/positional.golden")))))
+		})
+	})
+	Context("Remaps", func() {
+		It("should document a remapped metric with its corrected namespace and subsystem", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/swapped"}, config: lo.Must(loadConfig("testdata/config/remaps.yaml"))})
+			Expect(out).To(ContainSubstring("### `karpenter_nodes_drifted_total`\nNumber of nodes found to have drifted in total.\n- Stability Level: BETA\n"))
+			Expect(strings.Count(out, "## Nodes Metrics\n")).To(Equal(1))
+			Expect(out).ToNot(ContainSubstring("nodes_karpenter_drifted_total"))
+		})
+		It("should document metrics as they're declared without a remap", func() {
+			Expect(generate("testdata/swapped")).To(ContainSubstring("### `nodes_karpenter_drifted_total`\n"))
+		})
+	})
+	Context("Relationship Graph", func() {
+		It("should render the related metrics as a Mermaid graph after the metrics", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/graph.yaml")), emitGraph: true})
+			Expect(out).To(HaveSuffix("## Metric Relationships\n\n```mermaid\ngraph LR\n" +
+				"  karpenter_nodeclaims_launched_total <--> karpenter_nodes_registered_total\n" +
+				"  karpenter_nodeclaims_launched_total --> karpenter_nodepools_ready\n" +
+				"```\n"))
+		})
+		It("should not render the graph without related metrics", func() {
+			Expect(generateWithOptions(Options{roots: []string{"testdata/controllers"}, emitGraph: true})).ToNot(ContainSubstring("```mermaid"))
+		})
+	})
+	Context("Recording Rules", func() {
+		var cfg *config
+		BeforeEach(func() {
+			cfg = &config{Metrics: map[string]metricConfig{
+				"karpenter_nodeclaims_launched_total": {Lifecycle: lifecycleBeta},
+				"nodepools":                           {Lifecycle: lifecycleStable},
+			}}
+		})
+		It("should only emit rules for beta and stable metrics by default", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, format: formatRecordingRules, config: cfg, noSynthetic: true})
+			Expect(out).To(Equal("groups:\n" +
+				"- name: karpenter-metrics\n" +
+				"  rules:\n" +
+				"  - expr: rate(karpenter_nodeclaims_launched_total[5m])\n" +
+				"    record: karpenter_nodeclaims_launched:rate5m\n"))
+		})
+		It("should emit rules for alpha metrics when the minimum stability allows them", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, format: formatRecordingRules, config: cfg, noSynthetic: true, minStabilityForRules: lifecycleAlpha})
+			Expect(out).To(ContainSubstring("record: karpenter_nodeclaims_launched:rate5m\n"))
+			Expect(out).To(ContainSubstring("record: karpenter_nodes_registered:rate5m\n"))
+		})
+	})
+	Context("Downsampling", func() {
+		It("should recommend the recording rule of an annotated metric that a rule is emitted for", func() {
+			cfg := &config{Metrics: map[string]metricConfig{"karpenter_scheduler_simulation_duration_seconds": {Lifecycle: lifecycleBeta}}}
+			out := generateWithOptions(Options{roots: []string{"testdata/downsample"}, config: cfg})
+			Expect(out).To(ContainSubstring("- Downsampling: recommended, query the recording rule `karpenter_scheduler_simulation_duration_seconds:p99_rate5m`\n"))
+			// queue_wait_seconds is alpha so no rule is emitted for it
+			Expect(strings.Count(out, "- Downsampling:")).To(Equal(1))
+		})
+	})
+	Context("Config", func() {
+		It("should reject keys that aren't in the schema, reporting their lines", func() {
+			_, err := loadConfig("testdata/config/misspelled.yaml")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("line 5: metrics.karpenter_nodes_allocatable.stabel is a forbidden property"))
+			Expect(err.Error()).To(ContainSubstring("line 10: templatedMetrics[0].type should be one of [counter gauge histogram summary]"))
+		})
+	})
+	Context("Legacy Metrics", func() {
+		It("should document the legacy status condition and termination metrics as deprecated", func() {
+			out := generate("testdata/controllers")
+			Expect(out).To(ContainSubstring("### `operator_status_condition_count`\n" +
+				"The number of an condition for a given object, type and status. e.g. Alarm := Available=False > 0\n" +
+				"- Stability Level: DEPRECATED\n" +
+				"- Deprecation reason: replaced by the status condition metrics of each kind, e.g. operator_nodeclaim_status_condition_count\n"))
+			Expect(out).To(ContainSubstring("### `operator_termination_duration_seconds`\n" +
+				"The amount of time taken by an object to terminate completely.\n" +
+				"- Stability Level: DEPRECATED\n"))
+		})
+		It("should let the config for the qualified name override the deprecation", func() {
+			cfg := &config{Metrics: map[string]metricConfig{"operator_status_condition_count": {Lifecycle: lifecycleStable}, "status_condition": {Lifecycle: lifecycleBeta}}}
+			metrics := allMetrics(Options{roots: []string{"testdata/controllers"}, config: cfg})
+			count, _ := lo.Find(metrics, func(m metricInfo) bool { return m.qualifiedName() == "operator_status_condition_count" })
+			Expect(cfg.lifecycle(count)).To(Equal(lifecycleStable))
+			transitions, _ := lo.Find(metrics, func(m metricInfo) bool { return m.qualifiedName() == "operator_status_condition_transitions_total" })
+			Expect(cfg.lifecycle(transitions)).To(Equal(lifecycleDeprecated))
+		})
+	})
+	Context("Deprecation Timeline", func() {
+		It("should render when a deprecated metric was deprecated and is planned to be removed", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/deprecated.yaml"))})
+			Expect(out).To(ContainSubstring("### `karpenter_nodes_registered_total`\nNumber of nodes registered in total by Karpenter.\n" +
+				"- Stability Level: DEPRECATED\n- Deprecated since v1.3.0, removal planned v1.6.0\n\n"))
+			Expect(out).ToNot(ContainSubstring("removal planned v1.6.0\n- Deprecation reason:"))
+		})
+		It("should render why a deprecated metric was deprecated", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/deprecated_reason.yaml"))})
+			Expect(out).To(ContainSubstring("### `karpenter_nodes_registered_total`\nNumber of nodes registered in total by Karpenter.\n" +
+				"- Stability Level: DEPRECATED\n- Deprecated since v1.3.0, removal planned v1.6.0\n- Deprecation reason: replaced by histogram-based timing\n\n"))
+		})
+		It("should fail to load a removal that isn't planned after the deprecation", func() {
+			_, err := loadConfig("testdata/config/invalid_deprecation.yaml")
+			Expect(err).To(MatchError(ContainSubstring("removal must be planned for a version after the deprecation")))
+		})
+		It("should fail to load a version that isn't semantic", func() {
+			cfg := &config{Metrics: map[string]metricConfig{"karpenter_nodes_registered_total": {Lifecycle: lifecycleDeprecated, DeprecatedSince: "1.3"}}}
+			Expect(cfg.validate()).To(MatchError(ContainSubstring("invalid version")))
+		})
+	})
+	Context("Label Schema", func() {
+		It("should describe the labels of each metric keyed by its qualified name", func() {
+			opts := withDefaults(Options{roots: []string{"testdata/createdtimestamps"}, config: lo.Must(loadConfig("testdata/config/label_values.yaml")), noSynthetic: true})
+			out := &bytes.Buffer{}
+			writeLabelSchema(out, opts, getCheckedMetrics(opts))
+			var schema labelSchema
+			Expect(json.Unmarshal(out.Bytes(), &schema)).To(Succeed())
+			Expect(schema.Defs).To(HaveKey("karpenter_instances_running"))
+			Expect(schema.Defs["karpenter_instances_launched_total"]).To(Equal(labelSetSchema{
+				Description: "Number of instances launched in total.",
+				Type:        "object",
+				Properties:  map[string]labelSchemaProperty{"capacity_type": {Type: "string", Enum: []string{"spot", "on-demand"}}},
+				Required:    []string{"capacity_type"},
+			}))
+		})
+		It("should allow the undeclared labels of synthetic metrics", func() {
+			opts := withDefaults(Options{roots: []string{"testdata/controllers"}})
+			out := &bytes.Buffer{}
+			writeLabelSchema(out, opts, getCheckedMetrics(opts))
+			Expect(out.String()).To(ContainSubstring("\"operator_nodepool_status_condition_count\": {\n" +
+				"      \"description\": \"The number of a condition for a nodepool, type and status. Labeled by the name, namespace, type, status, and reason.\",\n" +
+				"      \"type\": \"object\",\n" +
+				"      \"properties\": {},\n" +
+				"      \"additionalProperties\": true\n"))
+		})
+	})
+	Context("Cheat Sheet", func() {
+		It("should list the metrics of each use case with the first line of their help", func() {
+			opts := withDefaults(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/usecases.yaml"))})
+			out := &bytes.Buffer{}
+			writeCheatSheet(out, opts, getCheckedMetrics(opts))
+			Expect(out.String()).To(Equal("<!-- this document is generated from hack/docs/metrics_gen/main.go -->\n" +
+				"## Metrics Cheat Sheet\n\n" +
+				"### Node Lifecycle\n" +
+				"- `karpenter_nodes_registered_total`: Number of nodes registered in total by Karpenter.\n" +
+				"- `karpenter_nodeclaims_launched_total`: Number of nodeclaims launched in total by Karpenter.\n"))
+		})
+		It("should flag metrics listed under a use case that aren't declared", func() {
+			cfg := lo.Must(loadConfig("testdata/config/usecases.yaml"))
+			warnings := lintUseCases(cfg, allMetrics(Options{roots: []string{"testdata/controllers"}, config: cfg}))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("use-case"))
+			Expect(warnings[0].strict).To(BeTrue())
+			Expect(warnings[0].message).To(Equal("karpenter_nodes_terminated_total is listed under the use case node_lifecycle but isn't declared"))
+		})
+	})
+	Context("Deprecation Notice", func() {
+		It("should list every deprecated metric with its timeline and reason", func() {
+			opts := withDefaults(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/deprecation_notice.yaml")), noSynthetic: true})
+			out := &bytes.Buffer{}
+			writeDeprecationNotice(out, opts, getCheckedMetrics(opts))
+			Expect(out.String()).To(Equal("<!-- this document is generated from hack/docs/metrics_gen/main.go -->\n" +
+				"## Deprecated Metrics\n\n" +
+				"The following metrics are deprecated and will be removed. Migrate any dashboards or alerts that depend on them.\n\n" +
+				"### `karpenter_nodes_registered_total`\n" +
+				"- Stability Level: DEPRECATED\n" +
+				"- Deprecated since v1.3.0, removal planned v1.6.0\n" +
+				"- Deprecation reason: replaced by karpenter_nodeclaims_launched_total\n" +
+				"- Related: `karpenter_nodeclaims_launched_total`\n\n" +
+				"### `karpenter_nodepools_ready`\n" +
+				"- Stability Level: PENDING REMOVAL\n" +
+				"- Removal planned v1.4.0\n"))
+		})
+		It("should note when no metrics are deprecated", func() {
+			opts := withDefaults(Options{roots: []string{"testdata/controllers"}, noSynthetic: true})
+			out := &bytes.Buffer{}
+			writeDeprecationNotice(out, opts, getCheckedMetrics(opts))
+			Expect(out.String()).To(HaveSuffix("## Deprecated Metrics\n\nNo metrics are deprecated.\n"))
+		})
+	})
+	Context("Related Metrics", func() {
+		It("should link a metric to the metrics that are configured as related to it", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/related.yaml"))})
+			Expect(out).To(ContainSubstring("### `karpenter_nodeclaims_launched_total`\nNumber of nodeclaims launched in total by Karpenter.\n" +
+				"- Related: `karpenter_nodes_registered_total`\n- Stability Level: ALPHA\n"))
+		})
+		It("should flag related metrics that aren't declared", func() {
+			warnings := lintRelated(lo.Must(loadConfig("testdata/config/related.yaml")), allMetrics(Options{roots: []string{"testdata/controllers"}}))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("related"))
+			Expect(warnings[0].strict).To(BeTrue())
+			Expect(warnings[0].message).To(Equal("karpenter_nodepools_missing is configured as related to karpenter_nodepools_ready but isn't declared"))
+		})
+		It("should flag stable metrics that are related to metrics that aren't stable", func() {
+			warnings := lintRelatedStability(lo.Must(loadConfig("testdata/config/related_stability.yaml")), allMetrics(Options{roots: []string{"testdata/controllers"}}))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("related-stability"))
+			Expect(warnings[0].strict).To(BeFalse())
+			Expect(warnings[0].message).To(Equal("karpenter_nodeclaims_launched_total is stable but is related to karpenter_nodes_registered_total, which is BETA"))
+		})
+		It("should not flag metrics that aren't stable", func() {
+			Expect(lintRelatedStability(lo.Must(loadConfig("testdata/config/related.yaml")), allMetrics(Options{roots: []string{"testdata/controllers"}}))).To(BeEmpty())
+		})
+	})
+	Context("Subsystem Descriptions", func() {
+		It("should render the description of a subsystem under its heading", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/descriptions.yaml"))})
+			Expect(out).To(ContainSubstring("## Nodeclaims Metrics\n\nMetrics about the NodeClaim lifecycle, from launch to termination.\n\n### `karpenter_nodeclaims_launched_total`\n"))
+			Expect(out).To(ContainSubstring("## Nodes Metrics\n\n### `karpenter_nodes_registered_total`\n"))
+		})
+	})
+	Context("Templated Metrics", func() {
+		It("should document a templated family as a single entry noting its variants", func() {
+			out := generateWithOptions(Options{config: lo.Must(loadConfig("testdata/config/templated.yaml"))})
+			Expect(out).To(ContainSubstring("### `karpenter_cloudprovider_{capacity_type}_instances_launched_total`\n" +
+				"Number of {capacity_type} instances launched in total.\n" +
+				"- Variants: one metric per capacity_type, spot, on-demand, reserved\n"))
+		})
+		It("should document each variant of an expanded templated family", func() {
+			cfg := lo.Must(loadConfig("testdata/config/templated.yaml"))
+			cfg.TemplatedMetrics[0].Expand = true
+			metrics := lo.Filter(allMetrics(Options{config: cfg}), func(m metricInfo, _ int) bool { return m.Subsystem == "cloudprovider" })
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ConsistOf(
+				"karpenter_cloudprovider_spot_instances_launched_total",
+				"karpenter_cloudprovider_on-demand_instances_launched_total",
+				"karpenter_cloudprovider_reserved_instances_launched_total",
+			))
+			Expect(metrics).To(HaveEach(HaveField("Synthetic", BeTrue())))
+			Expect(metrics[0].Help).ToNot(ContainSubstring("{capacity_type}"))
+		})
+		It("should fail to load a templated family without its dimension placeholder", func() {
+			_, err := loadConfig("testdata/config/invalid_templated.yaml")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("Wrappers", func() {
+		It("should extract the metrics declared by the configured wrappers from their positional arguments", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/wrappers"}, config: lo.Must(loadConfig("testdata/config/wrappers.yaml"))})
+			Expect(metrics).To(HaveLen(3))
+			handled, ok := lo.Find(metrics, func(m metricInfo) bool { return m.Name == "requests_handled_total" })
+			Expect(ok).To(BeTrue())
+			Expect(handled.qualifiedName()).To(Equal("karpenter_grpc_requests_handled_total"))
+			Expect(handled.Help).To(Equal("Number of requests handled in total."))
+			Expect(handled.MetricType).To(Equal(metricTypeCounter))
+			Expect(handled.Labels).To(Equal([]string{"method", "code"}))
+			duration, ok := lo.Find(metrics, func(m metricInfo) bool { return m.Name == "request_duration_seconds" })
+			Expect(ok).To(BeTrue())
+			Expect(duration.qualifiedName()).To(Equal("karpenter_interruption_request_duration_seconds"))
+			Expect(duration.MetricType).To(Equal(metricTypeHistogram))
+			Expect(duration.Labels).To(BeNil())
+		})
+		It("should report the arguments of a wrapper that can't be resolved", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/wrappers"}, config: lo.Must(loadConfig("testdata/config/wrappers.yaml"))})
+			dropped, ok := lo.Find(metrics, func(m metricInfo) bool { return m.Help == "Number of requests dropped in total." })
+			Expect(ok).To(BeTrue())
+			Expect(dropped.Name).To(Equal("droppedName"))
+			Expect(dropped.Unresolved).To(Equal([]string{"Name"}))
+		})
+		It("should ignore wrappers that aren't configured", func() {
+			Expect(declaredMetrics(Options{roots: []string{"testdata/wrappers"}})).To(BeEmpty())
+		})
+		It("should fail to load a wrapper that doesn't take the name of the metric", func() {
+			_, err := loadConfig("testdata/config/invalid_wrapper.yaml")
+			Expect(err).To(MatchError(ContainSubstring("wrapper must take the name of the metric as an argument")))
+		})
+	})
+	Context("Grouping", func() {
+		It("should group metrics by the controller package that declares them", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, groupBy: groupByController})
+			Expect(out).To(ContainSubstring("## nodeclaim Metrics\n\n" +
+				"### `karpenter_nodeclaims_launched_total`\nNumber of nodeclaims launched in total by Karpenter.\n- Stability Level: ALPHA\n\n" +
+				"### `karpenter_nodes_registered_total`\nNumber of nodes registered in total by Karpenter.\n- Stability Level: ALPHA\n\n" +
+				"## nodepool Metrics\n\n" +
+				"### `karpenter_nodepools_ready`\nNumber of nodepools that are ready.\n- Stability Level: ALPHA\n"))
+		})
+		It("should nest the subsystems of each namespace under a section for the namespace", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers", "testdata/libraries"}, groupBy: groupByNamespace})
+			Expect(out).To(Equal(string(lo.Must(os.ReadFile("testdata/namespaces.golden")))))
+		})
+		It("should group metrics by subsystem by default", func() {
+			out := generate("testdata/controllers")
+			Expect(out).To(ContainSubstring("## Nodeclaims Metrics\n"))
+			Expect(out).To(ContainSubstring("## Nodes Metrics\n"))
+			Expect(out).ToNot(ContainSubstring("## nodeclaim Metrics\n"))
+		})
+	})
+	Context("Split By Subsystem", func() {
+		It("should weight each subsystem's document by the order of the subsystem in the single page", func() {
+			sections := splitBySubsystem(allMetrics(Options{roots: []string{"testdata/controllers"}}))
+			weights := lo.SliceToMap(sections, func(s section) (string, int) { return s.subsystem, s.weight })
+			Expect(lo.Map(sections, func(s section, _ int) int { return s.weight })).To(Equal(lo.RangeFrom(1, len(sections))))
+			Expect(weights["nodeclaims"]).To(BeNumerically("<", weights["nodes"]))
+			Expect(weights["nodes"]).To(BeNumerically("<", weights["nodepools"]))
+			Expect(weights["nodepools"]).To(BeNumerically("<", weights["status_condition"]))
+		})
+		It("should render the weight in the front matter of each subsystem's document", func() {
+			docs := GenerateSplitMetricsDocs(withDefaults(Options{roots: []string{"testdata/controllers"}}))
+			Expect(string(docs["nodeclaims.md"])).To(HavePrefix("---\ntitle: \"Nodeclaims Metrics\"\nlinkTitle: \"Nodeclaims\"\nweight: 1\n---\n"))
+			Expect(string(docs["nodes.md"])).To(ContainSubstring("### `karpenter_nodes_registered_total`\n"))
+		})
+		It("should only rewrite the documents whose content changed when incremental", func() {
+			dir := GinkgoT().TempDir()
+			opts := withDefaults(Options{roots: []string{"testdata/controllers"}, incremental: true})
+			docs := GenerateSplitMetricsDocs(opts)
+			for name, out := range docs {
+				writeOutput(filepath.Join(dir, name), out, opts)
+			}
+			past := time.Now().Add(-time.Hour).Truncate(time.Second)
+			for name := range docs {
+				Expect(os.Chtimes(filepath.Join(dir, name), past, past)).To(Succeed())
+			}
+
+			docs["nodes.md"] = append(docs["nodes.md"], "\nAn addition.\n"...)
+			for name, out := range docs {
+				writeOutput(filepath.Join(dir, name), out, opts)
+			}
+			Expect(lo.Must(os.Stat(filepath.Join(dir, "nodeclaims.md"))).ModTime()).To(Equal(past))
+			Expect(lo.Must(os.Stat(filepath.Join(dir, "nodes.md"))).ModTime()).To(BeTemporally(">", past))
+			Expect(string(lo.Must(os.ReadFile(filepath.Join(dir, "nodes.md"))))).To(HaveSuffix("\nAn addition.\n"))
+		})
+	})
+	Context("Group Libraries", func() {
+		It("should document the metrics of libraries under a single section", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/libraries", "testdata/controllers"}, groupLibraries: true})
+			Expect(out).To(ContainSubstring("## Library Metrics\n\n" +
+				"- Controller Runtime: `controller_runtime_reconcile_total`, `controller_runtime_reconcile_errors_total`\n" +
+				"- Client Go: `client_go_request_duration_seconds`\n\n" +
+				"### `controller_runtime_reconcile_total`\n"))
+			Expect(out).ToNot(ContainSubstring("## Controller Runtime Metrics\n"))
+			Expect(strings.Index(out, "## Library Metrics")).To(BeNumerically(">", strings.Index(out, "### `karpenter_nodeclaims_launched_total`")))
+		})
+		It("should split the configured library prefixes into subsystems", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/grpclibrary"}, config: lo.Must(loadConfig("testdata/config/grpc_library.yaml"))})
+			Expect(metrics).To(HaveLen(1))
+			Expect(metrics[0].Subsystem).To(Equal("grpc_server"))
+			Expect(metrics[0].Name).To(Equal("handled_total"))
+			Expect(generateWithOptions(Options{roots: []string{"testdata/grpclibrary"}, config: lo.Must(loadConfig("testdata/config/grpc_library.yaml")), groupLibraries: true})).
+				To(ContainSubstring("## Library Metrics\n\n- Grpc Server: `grpc_server_handled_total`\n"))
+		})
+		It("should leave the names of metrics without a configured library prefix as declared", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/grpclibrary"}})
+			Expect(metrics).To(HaveLen(1))
+			Expect(metrics[0].Subsystem).To(BeEmpty())
+			Expect(metrics[0].Name).To(Equal("grpc_server_handled_total"))
+		})
+		It("should split the built-in library prefixes into subsystems when the config doesn't list the libraries", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/libraries"}, config: lo.Must(loadConfig("testdata/config/deprecated.yaml"))})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.Subsystem })).To(ContainElements("controller_runtime", "client_go"))
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.Name })).To(ContainElement("reconcile_total"))
+		})
+		It("should not split any library prefixes when the config lists no libraries", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/libraries"}, config: lo.Must(loadConfig("testdata/config/no_libraries.yaml"))})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.Subsystem })).To(HaveEach(BeEmpty()))
+		})
+		It("should document each library under its own section by default", func() {
+			out := generate("testdata/libraries")
+			Expect(out).To(ContainSubstring("## Controller Runtime Metrics\n"))
+			Expect(out).To(ContainSubstring("## Client Go Metrics\n"))
+			Expect(out).ToNot(ContainSubstring("## Library Metrics\n"))
+		})
+	})
+	Context("Sort Libraries Last", func() {
+		It("should document the metrics of libraries after the metrics of Karpenter", func() {
+			metrics := allMetrics(Options{roots: []string{"testdata/controllers", "testdata/libraries"}, sortLibrariesLast: true})
+			libraryMetrics := lo.Filter(metrics, func(m metricInfo, _ int) bool { return m.library(lo.Must(loadConfig(""))) })
+			Expect(libraryMetrics).To(HaveLen(3))
+			Expect(metrics[len(metrics)-len(libraryMetrics):]).To(Equal(libraryMetrics))
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers", "testdata/libraries"}, sortLibrariesLast: true})
+			Expect(strings.Index(out, "## Controller Runtime Metrics\n")).To(BeNumerically(">", strings.Index(out, "### `operator_status_condition_count`\n")))
+		})
+		It("should sort the metrics of libraries among the subsystems by default", func() {
+			out := generate("testdata/controllers", "testdata/libraries")
+			Expect(strings.Index(out, "## Controller Runtime Metrics\n")).To(BeNumerically("<", strings.Index(out, "### `operator_status_condition_count`\n")))
+		})
+	})
+	Context("Collapse Libraries To Link", func() {
+		It("should replace the metrics of a library with a link to its upstream documentation", func() {
+			cfg := lo.Must(loadConfig("testdata/config/library_docs.yaml"))
+			out := generateWithOptions(Options{roots: []string{"testdata/libraries"}, config: cfg, collapseLibraries: true})
+			Expect(out).To(ContainSubstring("## Controller Runtime Metrics\n\nThe Controller Runtime metrics are documented [upstream](https://example.com/controller-runtime/metrics).\n\n## "))
+			Expect(out).ToNot(ContainSubstring("controller_runtime_reconcile_total"))
+			Expect(out).To(ContainSubstring("### `client_go_request_duration_seconds`\n"))
+		})
+		It("should link to the upstream documentation from the library section", func() {
+			cfg := lo.Must(loadConfig("testdata/config/library_docs.yaml"))
+			out := generateWithOptions(Options{roots: []string{"testdata/libraries"}, config: cfg, collapseLibraries: true, groupLibraries: true})
+			Expect(out).To(ContainSubstring("## Library Metrics\n\n" +
+				"- Controller Runtime: documented [upstream](https://example.com/controller-runtime/metrics)\n" +
+				"- Client Go: `client_go_request_duration_seconds`\n\n" +
+				"### `client_go_request_duration_seconds`\n"))
+			Expect(out).ToNot(ContainSubstring("### `controller_runtime_reconcile_total`"))
+		})
+		It("should list the metrics of libraries by default", func() {
+			cfg := lo.Must(loadConfig("testdata/config/library_docs.yaml"))
+			Expect(generateWithOptions(Options{roots: []string{"testdata/libraries"}, config: cfg})).To(ContainSubstring("### `controller_runtime_reconcile_total`\n"))
+		})
+	})
+	Context("Compact", func() {
+		It("should render without blank lines between metric entries", func() {
+			Expect(generateWithOptions(Options{roots: []string{"testdata/controllers"}, compact: true})).To(Equal(string(lo.Must(os.ReadFile("testdata/compact.golden")))))
+		})
+	})
+	Context("Determinism", func() {
+		It("should generate identical output on repeated runs", func() {
+			for _, f := range formats {
+				opts := Options{roots: []string{"testdata"}, format: f, config: lo.Must(loadConfig("testdata/config/templated.yaml"))}
+				first := generateWithOptions(opts)
+				for range 10 {
+					Expect(generateWithOptions(opts)).To(Equal(first), "format %s", f)
+				}
+			}
+		})
+		It("should document the declaration of a duplicated metric from the first file by name", func() {
+			for range 10 {
+				metric, ok := lo.Find(declaredMetrics(Options{roots: []string{"testdata/determinism"}}), func(m metricInfo) bool { return m.Name == "evictions_total" })
+				Expect(ok).To(BeTrue())
+				Expect(metric.Labels).To(Equal([]string{"reason"}))
+				Expect(metric.Position.Filename).To(Equal("testdata/determinism/a.go"))
+			}
+		})
+	})
+	Context("Formats", func() {
+		It("should render the Prometheus HELP and TYPE metadata of each metric", func() {
+			Expect(generateWithOptions(Options{roots: []string{"testdata/controllers"}, format: formatPrometheusDocs})).To(Equal(string(lo.Must(os.ReadFile("testdata/prometheus_docs.golden")))))
+		})
+		It("should render Confluence wiki markup", func() {
+			opts := Options{roots: []string{"testdata/controllers"}, format: formatConfluence, config: lo.Must(loadConfig("testdata/config/deprecated.yaml"))}
+			Expect(generateWithOptions(opts)).To(Equal(string(lo.Must(os.ReadFile("testdata/confluence.golden")))))
+		})
+		It("should render each format from a single parse, naming the documents by their extensions", func() {
+			stats := &generationStats{}
+			docs := GenerateFormattedMetricsDocs(withDefaults(Options{roots: []string{"testdata/controllers"}, stats: stats}), []format{formatMarkdown, formatJSON, formatCSV}, "metrics.{ext}")
+			Expect(lo.Keys(docs)).To(ConsistOf("metrics.md", "metrics.json", "metrics.csv"))
+			Expect(stats.PackagesParsed).To(Equal(2))
+			Expect(string(docs["metrics.md"])).To(Equal(generate("testdata/controllers")))
+
+			var records []documentedMetric
+			Expect(json.Unmarshal(docs["metrics.json"], &records)).To(Succeed())
+			Expect(records[0]).To(Equal(documentedMetric{Name: "karpenter_nodeclaims_launched_total", Type: "counter", Help: "Number of nodeclaims launched in total by Karpenter.", StabilityLevel: "ALPHA"}))
+			rows := lo.Must(csv.NewReader(bytes.NewReader(docs["metrics.csv"])).ReadAll())
+			Expect(rows[0]).To(Equal([]string{"name", "type", "help", "labels", "stability_level"}))
+			Expect(rows).To(HaveLen(len(records) + 1))
+		})
+	})
+	Context("Collapsible Help", func() {
+		It("should collapse help text longer than the threshold into a details element", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/longhelp"}, collapsibleHelp: 80})
+			Expect(out).To(ContainSubstring("### `karpenter_disruption_decision_duration_seconds`\n<details>\n" +
+				"<summary>Duration of disruption decisions in seconds. A decision spans simulating the…</summary>\n\n" +
+				"Duration of disruption decisions in seconds. A decision spans simulating the scheduling of the pods on the candidate nodes, " +
+				"validating that the candidates are still disruptable after the validation period, and launching any replacement nodes.\n\n" +
+				"</details>\n\n- Stability Level: ALPHA\n"))
+			Expect(out).To(ContainSubstring("### `karpenter_disruption_candidates`\nNumber of disruption candidates.\n- Stability Level: ALPHA\n"))
+		})
+		It("should not collapse help text by default", func() {
+			Expect(generate("testdata/longhelp")).ToNot(ContainSubstring("<details>"))
+		})
+	})
+	Context("Escape Markdown", func() {
+		It("should escape emphasis in help text, keeping inline code as written", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/markdown"}, escapeMarkdown: true})
+			Expect(out).To(ContainSubstring("### `karpenter_scheduler_pods_matched`\n" +
+				"Number of pods matching \\*any\\* node\\_selector term, see `node_selector_terms` and `*_affinity`.\n"))
+		})
+		It("should escape a backtick without a closing backtick", func() {
+			Expect(escapeMarkdown("Number of `pods that match")).To(Equal("Number of \\`pods that match"))
+		})
+		It("should render help text as written by default", func() {
+			Expect(generate("testdata/markdown")).To(ContainSubstring("Number of pods matching *any* node_selector term, see `node_selector_terms` and `*_affinity`.\n"))
+		})
+	})
+	Context("Exclude Deprecated", func() {
+		It("should leave deprecated metrics out of the document when enabled", func() {
+			cfg := lo.Must(loadConfig("testdata/config/deprecated.yaml"))
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: cfg, excludeDeprecated: true})
+			Expect(out).ToNot(ContainSubstring("karpenter_nodes_registered_total"))
+			Expect(out).To(ContainSubstring("### `karpenter_nodeclaims_launched_total`\n"))
+		})
+		It("should document deprecated metrics by default", func() {
+			cfg := lo.Must(loadConfig("testdata/config/deprecated.yaml"))
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: cfg})
+			Expect(out).To(ContainSubstring("### `karpenter_nodes_registered_total`\nNumber of nodes registered in total by Karpenter.\n- Stability Level: DEPRECATED\n"))
+		})
+	})
+	Context("Subsystem Filters", func() {
+		metrics := []metricInfo{
+			{Namespace: "karpenter", Subsystem: "nodeclaims", Name: "created_total"},
+			{Namespace: "karpenter", Subsystem: "nodepools", Name: "usage"},
+			{Namespace: "karpenter", Subsystem: "nodes", Name: "allocatable"},
+		}
+		subsystems := func(metrics []metricInfo) []string {
+			return lo.Map(metrics, func(m metricInfo, _ int) string { return m.Subsystem })
+		}
+		It("should only keep the included subsystems", func() {
+			filtered, err := filterSubsystems(metrics, []string{"nodeclaims", "nodepools"}, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subsystems(filtered)).To(Equal([]string{"nodeclaims", "nodepools"}))
+		})
+		It("should drop the excluded subsystems", func() {
+			filtered, err := filterSubsystems(metrics, nil, []string{"nodes"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subsystems(filtered)).To(Equal([]string{"nodeclaims", "nodepools"}))
+		})
+		It("should exclude subsystems from the included subsystems", func() {
+			filtered, err := filterSubsystems(metrics, []string{"nodeclaims", "nodepools"}, []string{"nodepools", "nodes"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subsystems(filtered)).To(Equal([]string{"nodeclaims"}))
+		})
+		It("should fail when no metrics are left", func() {
+			_, err := filterSubsystems(metrics, []string{"nodepools"}, []string{"nodepools"})
+			Expect(err).To(MatchError(ContainSubstring("no metrics left to document after filtering subsystems")))
+		})
+		It("should filter the generated document", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, onlySubsystems: []string{"nodeclaims"}})
+			Expect(out).To(ContainSubstring("### `karpenter_nodeclaims_launched_total`\n"))
+			Expect(out).ToNot(ContainSubstring("karpenter_nodes_registered_total"))
+		})
+	})
+	Context("Namespace Filter", func() {
+		It("should only document the karpenter metrics", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, namespace: "karpenter"})
+			Expect(out).To(ContainSubstring("### `karpenter_nodeclaims_launched_total`\n"))
+			Expect(out).To(ContainSubstring("### `karpenter_nodepools_ready`\n"))
+			Expect(out).ToNot(ContainSubstring("`operator_"))
+		})
+		It("should only document the operator metrics", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, namespace: "operator"})
+			Expect(out).To(ContainSubstring("### `operator_nodepool_status_condition_count`\n"))
+			Expect(out).ToNot(ContainSubstring("`karpenter_"))
+		})
+		It("should filter the namespace before the subsystems", func() {
+			metrics := allMetrics(Options{roots: []string{"testdata/controllers"}})
+			filtered, err := filterNamespace(metrics, "karpenter")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = filterSubsystems(filtered, []string{"nodepool_status_condition"}, nil)
+			Expect(err).To(MatchError(ContainSubstring("no metrics left to document after filtering subsystems")))
+		})
+		It("should fail when no metrics are in the namespace", func() {
+			_, err := filterNamespace(allMetrics(Options{roots: []string{"testdata/controllers"}}), "kube")
+			Expect(err).To(MatchError(ContainSubstring("no metrics are in the namespace, the metrics are in [karpenter operator]")))
+		})
+	})
+	Context("Strikethrough Deprecated", func() {
+		It("should strike through the headings of deprecated metrics when enabled", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/deprecated.yaml")), strikethroughDeprecated: true})
+			Expect(out).To(Equal(string(lo.Must(os.ReadFile("testdata/strikethrough.golden")))))
+		})
+		It("should parse back the struck through headings of a markdown baseline", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/deprecated.yaml")), strikethroughDeprecated: true})
+			Expect(parseMarkdownBaseline([]byte(out))).To(ContainElement(documentedMetric{Name: "karpenter_nodes_registered_total", StabilityLevel: "DEPRECATED"}))
+		})
+		It("should not strike through headings by default", func() {
+			Expect(generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/deprecated.yaml"))})).ToNot(ContainSubstring("~~"))
+		})
+	})
+	Context("Legend", func() {
+		It("should render the stability levels after the introduction when enabled", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, legend: true})
+			Expect(out).To(ContainSubstring("documented [here](../settings)\n## Stability Levels\n\n" +
+				"- STABLE: The metric's name, type, and labels won't change without a deprecation period spanning multiple minor releases.\n"))
+			Expect(out).To(ContainSubstring("- PENDING REMOVAL: The metric will be removed in an upcoming release.\n\n## Nodeclaims Metrics\n"))
+		})
+		It("should prefer the configured explanation of a stability level", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/legend.yaml")), legend: true})
+			Expect(out).To(ContainSubstring("- ALPHA: Alpha metrics are experimental.\n"))
+			Expect(out).To(ContainSubstring("- BETA: The metric is unlikely to change, but its labels may be adjusted between minor releases.\n"))
+		})
+		It("should not render the stability levels by default", func() {
+			Expect(generate("testdata/controllers")).ToNot(ContainSubstring("## Stability Levels"))
+		})
+	})
+	Context("Alpha Banner", func() {
+		It("should render a warning callout under alpha metrics when enabled", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, alphaBanner: true})
+			Expect(out).To(ContainSubstring("### `karpenter_nodeclaims_launched_total`\nNumber of nodeclaims launched in total by Karpenter.\n- Stability Level: ALPHA\n\n" +
+				"> ⚠️ This metric is ALPHA and may change or be removed without notice.\n\n"))
+			Expect(out).To(ContainSubstring("### `operator_nodepool_status_condition_count`\nThe number of a condition for a nodepool, type and status. Labeled by the name, namespace, type, status, and reason.\n- Stability Level: BETA\n\n## "))
+		})
+		It("should not render the callout by default", func() {
+			Expect(generate("testdata/controllers")).ToNot(ContainSubstring("This metric is ALPHA"))
+		})
+	})
+	Context("Query Hints", func() {
+		It("should render a suggested query for each metric type when enabled", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers", "testdata/funcs"}, queryHints: true})
+			Expect(out).To(ContainSubstring("### `karpenter_nodeclaims_launched_total`\nNumber of nodeclaims launched in total by Karpenter.\n- Example Query: `rate(karpenter_nodeclaims_launched_total[5m])`\n"))
+			Expect(out).To(ContainSubstring("### `karpenter_runtime_goroutines`\nNumber of goroutines that currently exist.\n- Example Query: `karpenter_runtime_goroutines`\n"))
+			Expect(out).To(ContainSubstring("- Example Query: `histogram_quantile(0.99, rate(operator_termination_duration_seconds_bucket[5m]))`\n"))
+		})
+		It("should not render suggested queries by default", func() {
+			Expect(generate("testdata/controllers")).ToNot(ContainSubstring("Example Query"))
+		})
+	})
+	Context("Value Types", func() {
+		It("should infer the value type from the constructor of each metric", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/funcs", "testdata/counters", "testdata/buckets"}})
+			valueTypes := lo.SliceToMap(metrics, func(m metricInfo) (string, string) { return m.qualifiedName(), m.ValueType })
+			Expect(valueTypes).To(HaveKeyWithValue("karpenter_runtime_goroutines", "float64"))
+			Expect(valueTypes).To(HaveKeyWithValue("karpenter_runtime_workers_started_total", "float64"))
+			Expect(valueTypes).To(HaveKeyWithValue("karpenter_buckets_batch_size", "float64 sum, uint64 count"))
+			Expect(lo.Filter(metrics, func(m metricInfo, _ int) bool { return m.MetricType == metricTypeCounter && m.ValueType != "float64" })).To(BeEmpty())
+		})
+		It("should render the value type of each metric when enabled", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/funcs"}, valueTypes: true})
+			Expect(out).To(ContainSubstring("### `karpenter_runtime_goroutines`\nNumber of goroutines that currently exist.\n- Value type: float64\n"))
+		})
+		It("should not render value types by default", func() {
+			Expect(generate("testdata/funcs")).ToNot(ContainSubstring("Value type"))
+		})
+		It("should not infer the value type of synthetic metrics", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, valueTypes: true})
+			Expect(out).To(ContainSubstring("### `operator_nodepool_status_condition_count`\nThe number of a condition for a nodepool, type and status. Labeled by the name, namespace, type, status, and reason.\n- Stability Level: BETA\n"))
+		})
+	})
+	Context("Glossary", func() {
+		It("should link the first occurrence of each glossary term in help text", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/glossary"}, glossary: lo.Must(loadGlossary("testdata/glossary/glossary.yaml"))})
+			Expect(out).To(ContainSubstring("### `karpenter_nodeclaims_consolidated_total`\n" +
+				"Number of [NodeClaims](https://karpenter.sh/docs/concepts/nodeclaims/) disrupted by [consolidation](https://karpenter.sh/docs/concepts/disruption/#consolidation) in total, " +
+				"labeled by the `nodepool` of each NodeClaim.\n"))
+		})
+		It("should not link terms within inline code or existing links", func() {
+			g := glossary{"NodePool": "https://example.com/nodepools", "disruption": "https://example.com/disruption"}
+			Expect(g.link("Budgets of the `NodePool` for [disruption](https://example.com/budgets) of a NodePool.")).
+				To(Equal("Budgets of the `NodePool` for [disruption](https://example.com/budgets) of a [NodePool](https://example.com/nodepools)."))
+		})
+		It("should link longer terms before the terms that they contain", func() {
+			g := glossary{"disruption": "https://example.com/disruption", "disruption budget": "https://example.com/budgets"}
+			Expect(g.link("The disruption budget limits disruption.")).
+				To(Equal("The [disruption budget](https://example.com/budgets) limits [disruption](https://example.com/disruption)."))
+		})
+		It("should not link help text by default", func() {
+			Expect(generate("testdata/glossary")).To(ContainSubstring("Number of NodeClaims disrupted by consolidation in total"))
+		})
+	})
+	Context("Matrix", func() {
+		It("should render which versions document each metric", func() {
+			out, err := generateMatrix(withDefaults(Options{roots: []string{"testdata/controllers"}, noSynthetic: true}), []matrixInput{
+				{version: "v1.1", path: "testdata/matrix/v1.1.json"},
+				{version: "v1.2", path: "testdata/matrix/v1.2.json"},
+			}, baselineFormatJSON)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(out)).To(Equal("| Metric | v1.1 | v1.2 | current |\n" +
+				"|---|---|---|---|\n" +
+				"| `karpenter_nodeclaims_launched_total` | ✓ | ✓ | ✓ |\n" +
+				"| `karpenter_nodepools_ready` | ✗ | ✗ | ✓ |\n" +
+				"| `karpenter_nodes_allocatable` | ✓ | ✓ | ✗ |\n" +
+				"| `karpenter_nodes_registered_total` | ✗ | ✓ | ✓ |\n"))
+		})
+		It("should require each input to be labeled with its version", func() {
+			_, err := parseMatrixInput("testdata/matrix/v1.1.json")
+			Expect(err).To(MatchError(ContainSubstring("expected version=path")))
+		})
+	})
+	Context("Titles", func() {
+		It("should translate the titles of subsystems and sections", func() {
+			t := lo.Must(loadTitles("testdata/titles/de.yaml"))
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, titles: t, legend: true})
+			Expect(out).To(HavePrefix("---\ntitle: \"Metriken\"\nlinkTitle: \"Metriken\"\n"))
+			Expect(out).To(ContainSubstring("## Stabilitätsstufen\n"))
+			Expect(out).To(ContainSubstring("## NodeClaim-Metriken\n"))
+			Expect(out).ToNot(ContainSubstring("## Nodeclaims Metrics\n"))
+			// Subsystems without a translation keep their English title and help text stays as written
+			Expect(out).To(ContainSubstring("## Nodes Metrics\n"))
+			Expect(out).To(ContainSubstring("Number of nodeclaims launched"))
+		})
+		It("should translate the titles of split sections", func() {
+			t := lo.Must(loadTitles("testdata/titles/de.yaml"))
+			title, linkTitle := section{subsystem: "nodeclaims"}.title(t)
+			Expect([]string{title, linkTitle}).To(Equal([]string{"NodeClaim-Metriken", "NodeClaim-Metriken"}))
+			title, linkTitle = section{subsystem: "nodes"}.title(t)
+			Expect([]string{title, linkTitle}).To(Equal([]string{"Nodes Metrics", "Nodes"}))
+		})
+		It("should reject unknown sections", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "titles.yaml")
+			Expect(os.WriteFile(path, []byte("sections:\n  glossar: Glossar\n"), 0o600)).To(Succeed())
+			_, err := loadTitles(path)
+			Expect(err).To(MatchError(ContainSubstring("section must be one of")))
+		})
+	})
+	Context("Derived Series", func() {
+		It("should render the unit of each series of a histogram when enabled", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/annotations"}, derivedSeries: true})
+			Expect(out).To(ContainSubstring("### `karpenter_cluster_reconcile_duration_seconds`\nDuration of reconciles in seconds.\n- Exemplars: supported\n" +
+				"- Series: `karpenter_cluster_reconcile_duration_seconds_bucket` (le in seconds), `karpenter_cluster_reconcile_duration_seconds_sum` (seconds), " +
+				"`karpenter_cluster_reconcile_duration_seconds_count` (count)\n"))
+		})
+		It("should only render the series of histograms and summaries with a unit", func() {
+			Expect(derivedSeries(metricInfo{Name: "utilization_ratio", MetricType: metricTypeGauge})).To(BeEmpty())
+			Expect(derivedSeries(metricInfo{Name: "batch_size", MetricType: metricTypeHistogram})).To(BeEmpty())
+			Expect(derivedSeries(metricInfo{Name: "payload_bytes", MetricType: metricTypeSummary})).To(Equal("`payload_bytes` (quantiles in bytes), `payload_bytes_sum` (bytes), `payload_bytes_count` (count)"))
+		})
+		It("should not render the series by default", func() {
+			Expect(generate("testdata/annotations")).ToNot(ContainSubstring("- Series:"))
+		})
+	})
+	Context("Source Snippets", func() {
+		It("should render the declaration of each metric in a code block when enabled", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/typicalrates"}, sourceSnippets: true})
+			Expect(out).To(ContainSubstring("- Stability Level: ALPHA\n\n```go\n" +
+				"NodesEvaluated = prometheus.NewCounter(\n" +
+				"\tprometheus.CounterOpts{\n" +
+				"\t\tNamespace: metrics.Namespace,\n" +
+				"\t\tSubsystem: \"nodes\",\n" +
+				"\t\tName:      \"evaluated_total\",\n" +
+				"\t\tHelp:      \"Number of node evaluations in total.\",\n" +
+				"\t},\n" +
+				")\n```\n"))
+		})
+		It("should not render a snippet for metrics without a declaration", func() {
+			Expect(sourceSnippet(metricInfo{Name: "synthetic", Synthetic: true})).To(BeEmpty())
+		})
+		It("should not render the snippets by default", func() {
+			Expect(generate("testdata/typicalrates")).ToNot(ContainSubstring("```go"))
+		})
+	})
+	Context("Namespace Overrides", func() {
+		It("should rewrite the namespaces of the documented metrics", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/controllers"}, namespaceOverrides: map[string]string{"karpenter": "mycloud"}})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ConsistOf(
+				"mycloud_nodeclaims_launched_total",
+				"mycloud_nodes_registered_total",
+				"mycloud_nodepools_ready",
+			))
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, namespaceOverrides: map[string]string{"karpenter": "mycloud", "operator": "mycloud_operator"}})
+			Expect(out).To(ContainSubstring("### `mycloud_nodeclaims_launched_total`\n"))
+			Expect(out).To(ContainSubstring("### `mycloud_operator_nodepool_status_condition_count`\n"))
+			Expect(out).ToNot(ContainSubstring("karpenter_nodeclaims_launched_total"))
+		})
+		It("should configure a metric whose namespace was overridden by its declared name", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/deprecated.yaml")), namespaceOverrides: map[string]string{"karpenter": "mycloud"}})
+			Expect(out).To(ContainSubstring("### `mycloud_nodes_registered_total`\nNumber of nodes registered in total by Karpenter.\n- Stability Level: DEPRECATED\n"))
+		})
+		It("should link to related metrics by their overridden names", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/graph.yaml")), emitGraph: true, namespaceOverrides: map[string]string{"karpenter": "mycloud"}})
+			Expect(out).To(ContainSubstring("- Related: `mycloud_nodes_registered_total`, `mycloud_nodepools_ready`\n"))
+			Expect(out).To(HaveSuffix("```mermaid\ngraph LR\n" +
+				"  mycloud_nodeclaims_launched_total <--> mycloud_nodes_registered_total\n" +
+				"  mycloud_nodeclaims_launched_total --> mycloud_nodepools_ready\n" +
+				"```\n"))
+		})
+	})
+	Context("Transform", func() {
+		It("should render the metrics returned by the transform", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, transform: func(metrics []metricInfo) []metricInfo {
+				return lo.Reject(metrics, func(m metricInfo, _ int) bool { return m.Subsystem == "nodes" })
+			}})
+			Expect(out).To(ContainSubstring("### `karpenter_nodeclaims_launched_total`\n"))
+			Expect(out).ToNot(ContainSubstring("## Nodes Metrics\n"))
+			Expect(out).ToNot(ContainSubstring("karpenter_nodes_registered_total"))
+		})
+	})
+	Context("Lint", func() {
+		It("should only fail on warnings for bugs when strict", func() {
+			warnings := []warning{{rule: "counter-suffix"}, {rule: "label-conflict", strict: true}}
+			Expect(fatalWarnings(Options{}, warnings)).To(BeEmpty())
+			Expect(fatalWarnings(Options{strict: true}, warnings)).To(Equal(warnings[1:]))
+		})
+		It("should fail on every warning with -fail-on-warnings", func() {
+			warnings := []warning{{rule: "counter-suffix"}, {rule: "label-conflict", strict: true}, {rule: "unresolved"}}
+			Expect(fatalWarnings(Options{failOnWarnings: true}, warnings)).To(Equal(warnings))
+			Expect(fatalWarnings(Options{failOnWarnings: true, strict: true}, warnings)).To(Equal(warnings))
+			Expect(fatalWarnings(Options{failOnWarnings: true}, nil)).To(BeEmpty())
+		})
+		It("should report warnings as a JSON array with their severity, metric, and position", func() {
+			warnings := lint(allMetrics(Options{roots: []string{"testdata/reservedlabels", "testdata/annotations"}}))
+			out := &bytes.Buffer{}
+			Expect(writeReport(out, reportFormatJSON, warnings, fatalWarnings(Options{strict: true}, warnings))).To(Succeed())
+			var entries []map[string]string
+			Expect(json.Unmarshal(out.Bytes(), &entries)).To(Succeed())
+			Expect(entries).To(ConsistOf(
+				map[string]string{
+					"severity": "error",
+					"metric":   "karpenter_instances_launched_total",
+					"rule":     "reserved-label",
+					"message":  "karpenter_instances_launched_total at testdata/reservedlabels/metrics.go:26:22 declares the reserved label instance",
+					"position": "testdata/reservedlabels/metrics.go:26:22",
+				},
+				map[string]string{
+					"severity": "warning",
+					"metric":   "karpenter_cluster_backwards",
+					"rule":     "annotation",
+					"message":  `karpenter_cluster_backwards has a malformed range annotation, range "1..0" has a lower bound greater than its upper bound`,
+					"position": "testdata/annotations/metrics.go:46:14",
+				},
+			))
+		})
+		It("should report an empty JSON array without warnings", func() {
+			out := &bytes.Buffer{}
+			Expect(writeReport(out, reportFormatJSON, nil, nil)).To(Succeed())
+			Expect(out.String()).To(Equal("[]\n"))
+		})
+		It("should flag counter families that mix _total and non-_total names", func() {
+			warnings := lintCounterSuffixes(allMetrics(Options{roots: []string{"testdata/counters"}}))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("counter-suffix"))
+			Expect(warnings[0].message).To(ContainSubstring("karpenter_widgets"))
+			Expect(warnings[0].message).To(ContainSubstring("karpenter_widgets_deleted"))
+			Expect(warnings[0].message).ToNot(ContainSubstring("karpenter_widgets_created_total"))
+		})
+		It("should flag help text that's shared by distinct metrics", func() {
+			warnings := lintDuplicateHelp([]metricInfo{
+				{Namespace: "karpenter", Subsystem: "pods", Name: "bound_duration_seconds", Help: "The time from pod creation until the pod is bound."},
+				{Namespace: "karpenter", Subsystem: "pods", Name: "unbound_time_seconds", Help: "The time from pod creation until the pod is bound."},
+				{Namespace: "karpenter", Subsystem: "pods", Name: "startup_duration_seconds", Help: "The time from pod creation until the pod is running."},
+				{Namespace: "karpenter", Subsystem: "pods", Name: "pending"},
+				{Namespace: "karpenter", Subsystem: "pods", Name: "running"},
+			})
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("duplicate-help"))
+			Expect(warnings[0].strict).To(BeTrue())
+			Expect(warnings[0].message).To(Equal(`"The time from pod creation until the pod is bound." is the help of karpenter_pods_bound_duration_seconds, karpenter_pods_unbound_time_seconds`))
+		})
+		It("should flag metrics whose qualified names are longer than the limit", func() {
+			warnings := lintNameLength(allMetrics(Options{roots: []string{"testdata/controllers"}}), 34)
+			Expect(warnings).ToNot(BeEmpty())
+			Expect(lo.EveryBy(warnings, func(w warning) bool { return w.rule == "name-length" && w.strict })).To(BeTrue())
+			Expect(warnings[0].message).To(Equal("karpenter_nodeclaims_launched_total is 35 characters long, longer than the limit of 34"))
+			Expect(lo.ContainsBy(warnings, func(w warning) bool { return strings.HasPrefix(w.message, "karpenter_nodepools_ready ") })).To(BeFalse())
+		})
+		It("should not check the lengths of names without a limit", func() {
+			Expect(lintNameLength(allMetrics(Options{roots: []string{"testdata/controllers"}}), 0)).To(BeEmpty())
+		})
+		It("should flag metrics that are declared beneath more than one root", func() {
+			metrics := lo.Map([]string{"testdata/controllers", "testdata/crossroot"}, func(root string, _ int) []metricInfo {
+				return lo.Map(declaredMetrics(Options{roots: []string{root}}), func(m metricInfo, _ int) metricInfo {
+					m.Root = root
+					return m
+				})
+			})
+			warnings := lintCrossRootDupes(lo.Flatten(metrics))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("cross-root-dupe"))
+			Expect(warnings[0].strict).To(BeTrue())
+			Expect(warnings[0].message).To(Equal("karpenter_nodeclaims_launched_total is declared beneath both testdata/controllers at " +
+				"testdata/controllers/nodeclaim/metrics.go:24:23 and testdata/crossroot at testdata/crossroot/metrics.go:24:26"))
+		})
+		It("should not flag metrics that are declared more than once beneath the same root", func() {
+			Expect(lintCrossRootDupes([]metricInfo{
+				{Namespace: "karpenter", Name: "instances_total", Root: "pkg/", BuildConstraint: "linux"},
+				{Namespace: "karpenter", Name: "instances_total", Root: "pkg/", BuildConstraint: "windows"},
+			})).To(BeEmpty())
+		})
+		It("should flag metrics that are declared but never registered", func() {
+			warnings := lintRegistration(declaredMetrics(Options{roots: []string{"testdata/registration"}}))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("registration"))
+			Expect(warnings[0].strict).To(BeFalse())
+			Expect(warnings[0].metric).To(Equal("karpenter_queue_dropped_total"))
+			Expect(warnings[0].message).To(Equal("karpenter_queue_dropped_total at testdata/registration/metrics.go:35:17 is declared but never registered in its package"))
+		})
+		It("should flag config entries that don't match any metric, subsystem, label, package, or feature gate", func() {
+			cfg := lo.Must(loadConfig("testdata/config/unused.yaml"))
+			warnings := lintUnusedConfig(cfg, allMetrics(Options{roots: []string{"testdata/controllers", "testdata/curried"}, config: cfg}))
+			Expect(lo.Map(warnings, func(w warning, _ int) string { return w.message })).To(Equal([]string{
+				"metrics entry karpenter_nodeclaims_provisioned_total doesn't match any metric or subsystem",
+				"metrics entry nodepool doesn't match any metric or subsystem",
+				"subsystemDescriptions entry node_pools doesn't match any subsystem",
+				"labelValues entry capacity_type doesn't match any label",
+				"useCases entry karpenter_nodes_leaked_total doesn't match any metric",
+				"related entry karpenter_nodeclaims_leaked_total doesn't match any metric",
+				"packageConstLabels entry provisioner doesn't match any package",
+				"remaps entry karpenter_interruption_dropped_messages_total doesn't match any metric",
+				"templatedMetrics entry karpenter_nodeclaims_{action}_total doesn't match any undeclared metric",
+				"featureGates entry SpotToSpotConsolidation doesn't match any feature-gate annotation",
+			}))
+			Expect(warnings[0].rule).To(Equal("unused-config"))
+			Expect(warnings[0].strict).To(BeFalse())
+		})
+		It("should flag metrics extracted from the source without a position, exempting synthetic metrics", func() {
+			metrics := allMetrics(Options{roots: []string{"testdata/controllers"}})
+			Expect(lo.CountBy(metrics, func(m metricInfo) bool { return m.Synthetic && !m.Position.IsValid() })).To(BeNumerically(">", 0))
+			Expect(lintPositions(metrics)).To(BeEmpty())
+			warnings := lintPositions(append(metrics, metricInfo{Namespace: "karpenter", Subsystem: "nodes", Name: "leaked_total"}))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("position"))
+			Expect(warnings[0].strict).To(BeTrue())
+			Expect(warnings[0].message).To(Equal("karpenter_nodes_leaked_total was extracted from the source without a position"))
+		})
+		It("should tag synthetic metrics in the documented data", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/controllers"}, format: formatJSON})
+			Expect(out).To(ContainSubstring("\"name\": \"karpenter_nodeclaims_launched_total\",\n    \"type\": \"counter\",\n    \"help\": \"Number of nodeclaims launched in total by Karpenter.\",\n    \"stabilityLevel\": \"ALPHA\"\n  }"))
+			Expect(out).To(ContainSubstring("\"stabilityLevel\": \"BETA\",\n    \"synthetic\": true\n"))
+		})
+		It("should flag subsystems that are neither in the sort order nor titled", func() {
+			metrics := []metricInfo{
+				{Namespace: "karpenter", Subsystem: "nodes", Name: "created_total"},
+				{Namespace: "karpenter", Subsystem: "ignition", Name: "sparks_total"},
+				{Namespace: "karpenter", Subsystem: "ignition", Name: "flames_total"},
+				{Namespace: "karpenter", Subsystem: "cooling", Name: "fans"},
+			}
+			warnings := lintUnclassifiedSubsystems(titles{Subsystems: map[string]string{"cooling": "Kühlung"}}, metrics)
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("unclassified-subsystem"))
+			Expect(warnings[0].strict).To(BeFalse())
+			Expect(warnings[0].message).To(Equal(`subsystem ignition of 2 metrics is neither in the sort order nor titled, so it's sorted with the default priority and titled "Ignition"`))
+		})
+		It("should flag metrics that declare a label that's reserved by Prometheus", func() {
+			warnings := lintReservedLabels(allMetrics(Options{roots: []string{"testdata/reservedlabels"}}))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("reserved-label"))
+			Expect(warnings[0].strict).To(BeTrue())
+			Expect(warnings[0].message).To(Equal("karpenter_instances_launched_total at testdata/reservedlabels/metrics.go:26:22 declares the reserved label instance"))
+		})
+	})
+	Context("Annotations", func() {
+		It("should render the range annotation of a declaration", func() {
+			out := generate("testdata/annotations")
+			Expect(out).To(ContainSubstring("### `karpenter_cluster_utilization_ratio`\nFraction of the cluster's capacity that is requested by pods.\n- Range: 0 to 1\n"))
+			Expect(out).To(ContainSubstring("### `karpenter_cluster_zone_skew`\nSkew of pods across zones.\n- Range: -1 to 1\n"))
+		})
+		It("should render the exemplars annotation of a declaration", func() {
+			Expect(generate("testdata/annotations")).To(ContainSubstring("### `karpenter_cluster_reconcile_duration_seconds`\nDuration of reconciles in seconds.\n- Exemplars: supported\n"))
+		})
+		It("should render the created-timestamp series of a counter", func() {
+			out := generate("testdata/createdtimestamps")
+			Expect(out).To(ContainSubstring("### `karpenter_instances_launched_total`\nNumber of instances launched in total.\n- capacity_type: spot, on-demand, reserved\n- Created-timestamp series: `karpenter_instances_launched_created`\n"))
+			Expect(out).To(ContainSubstring("### `karpenter_instances_running`\nNumber of instances that are running.\n- Stability Level: ALPHA\n"))
+		})
+		It("should render the staleness annotation of a declaration as its update interval", func() {
+			Expect(generate("testdata/annotations")).To(ContainSubstring("### `karpenter_cluster_pending_pods`\nNumber of pods that are pending, sampled periodically.\n- Update interval: ~30s\n"))
+		})
+		It("should warn on a staleness that isn't a positive duration", func() {
+			warnings := lintAnnotations([]metricInfo{
+				{Name: "sampled", Annotations: map[string]string{"staleness": "often"}},
+				{Name: "negative", Annotations: map[string]string{"staleness": "-5s"}},
+			})
+			Expect(warnings).To(HaveLen(2))
+			Expect(warnings[0].message).To(HavePrefix(`sampled has a malformed staleness annotation, staleness "often" must be a duration such as 30s`))
+			Expect(warnings[1].message).To(Equal(`negative has a malformed staleness annotation, staleness "-5s" must be positive`))
+		})
+		It("should render the typical rate annotation of a counter with its magnitude", func() {
+			out := generate("testdata/typicalrates")
+			Expect(out).To(ContainSubstring("### `karpenter_nodes_drifted_total`\nNumber of nodes found to have drifted in total.\n- Typical rate: low (single-digit per minute)\n"))
+			Expect(out).To(ContainSubstring("### `karpenter_nodes_evaluated_total`\nNumber of node evaluations in total.\n- Stability Level: ALPHA\n"))
+		})
+		It("should warn on a typical rate that isn't one of the allowed values", func() {
+			warnings := lintAnnotations(declaredMetrics(Options{roots: []string{"testdata/typicalrates"}}))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].message).To(Equal(`karpenter_nodes_evaluated_total has a malformed typical-rate annotation, typical rate "often" must be one of [high low medium]`))
+		})
+		It("should warn on a malformed range and leave it out of the document", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/annotations"}})
+			warnings := lintAnnotations(metrics)
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("annotation"))
+			Expect(warnings[0].message).To(ContainSubstring("karpenter_cluster_backwards"))
+			Expect(generate("testdata/annotations")).To(ContainSubstring("### `karpenter_cluster_backwards`\nA gauge with a malformed range.\n- Stability Level: ALPHA\n"))
+		})
+	})
+	Context("Feature Gates", func() {
+		It("should render the feature gate that a metric requires", func() {
+			Expect(generate("testdata/featuregates")).To(ContainSubstring("### `karpenter_consolidation_spot_to_spot_replacements_total`\n" +
+				"Number of spot nodes replaced by cheaper spot nodes in total.\n- Feature Gate: SpotToSpotConsolidation (requires enablement)\n"))
+		})
+		It("should warn on feature gates that aren't known", func() {
+			warnings := lintFeatureGates(lo.Must(loadConfig("")), declaredMetrics(Options{roots: []string{"testdata/featuregates"}}))
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("feature-gate"))
+			Expect(warnings[0].strict).To(BeFalse())
+			Expect(warnings[0].message).To(HavePrefix("karpenter_consolidation_spot_to_spot_candidates requires the feature gate SpotToSpotConsolidaton, "))
+		})
+		It("should warn on a feature gate annotation without a value", func() {
+			warnings := lintAnnotations([]metricInfo{{Name: "gated", Annotations: map[string]string{"feature-gate": ""}}})
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].message).To(Equal("gated has a malformed feature-gate annotation, annotation requires a value"))
+		})
+	})
+	Context("Internal", func() {
+		It("should leave metrics annotated as internal out of the document", func() {
+			Expect(generate("testdata/annotations")).ToNot(ContainSubstring("karpenter_cluster_reconcile_cache_misses_total"))
+		})
+		It("should document metrics annotated as internal when they're included", func() {
+			out := generateWithOptions(Options{roots: []string{"testdata/annotations"}, includeInternal: true})
+			Expect(out).To(ContainSubstring("### `karpenter_cluster_reconcile_cache_misses_total`\nNumber of reconcile cache misses in total, for diagnosing the cache.\n"))
+		})
+	})
+	Context("Platforms", func() {
+		It("should document the metrics of every platform, noting those that are platform specific", func() {
+			out := generate("testdata/platforms")
+			Expect(out).To(ContainSubstring("### `karpenter_host_processes`\nNumber of processes running on the host.\n- Stability Level: ALPHA\n"))
+			Expect(out).To(ContainSubstring("### `karpenter_host_cgroup_throttled_total`\nNumber of times the cgroup was throttled.\n- Platform: only built when `linux`\n"))
+			Expect(out).To(ContainSubstring("### `karpenter_host_open_handles`\nNumber of handles open on the host.\n- Platform: only built when `windows && amd64`\n"))
+		})
+		DescribeTable("should derive the constraint of a file from its name",
+			func(path, expected string) {
+				Expect(buildConstraint(path, &ast.File{})).To(Equal(expected))
+			},
+			Entry("os", "metrics_linux.go", "linux"),
+			Entry("arch", "metrics_arm64.go", "arm64"),
+			Entry("os and arch", "metrics_windows_amd64.go", "windows && amd64"),
+			Entry("test", "metrics_linux_test.go", "linux"),
+			Entry("without an underscore", "linux.go", ""),
+			Entry("unknown suffix", "metrics_cgroup.go", ""),
+		)
+		It("should only document the metrics built for the platform", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/platforms"}, platform: lo.Must(parsePlatform("darwin/arm64"))})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ConsistOf("karpenter_host_processes"))
+			metrics = declaredMetrics(Options{roots: []string{"testdata/platforms"}, platform: lo.Must(parsePlatform("linux/amd64"))})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ConsistOf("karpenter_host_processes", "karpenter_host_cgroup_throttled_total"))
+		})
+		It("should fail to parse a platform without an architecture", func() {
+			_, err := parsePlatform("linux")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("Live Scrape", func() {
+		It("should report metrics that are documented but not exposed and exposed but not documented", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, "# HELP karpenter_nodeclaims_launched_total Number of nodeclaims launched in total by Karpenter.\n"+
+					"# TYPE karpenter_nodeclaims_launched_total counter\n"+
+					"karpenter_nodeclaims_launched_total 3\n"+
+					"# HELP karpenter_nodes_leaked Number of leaked nodes.\n"+
+					"# TYPE karpenter_nodes_leaked gauge\n"+
+					"karpenter_nodes_leaked 1\n")
+			}))
+			defer server.Close()
+			exposed := lo.Must(scrape(server.URL))
+			Expect(exposed).To(Equal([]string{"karpenter_nodeclaims_launched_total", "karpenter_nodes_leaked"}))
+
+			warnings := lintLiveScrape(declaredMetrics(Options{roots: []string{"testdata/controllers"}}), exposed)
+			Expect(lo.Map(warnings, func(w warning, _ int) string { return w.String() })).To(Equal([]string{
+				"[not-exposed] karpenter_nodepools_ready is documented but isn't exposed by the endpoint",
+				"[not-exposed] karpenter_nodes_registered_total is documented but isn't exposed by the endpoint",
+				"[undocumented] karpenter_nodes_leaked is exposed by the endpoint but isn't documented",
+			}))
+			Expect(lo.EveryBy(warnings, func(w warning) bool { return w.strict })).To(BeTrue())
+		})
+	})
+	Context("Stats", func() {
+		It("should count the packages parsed, the metrics found, and the duplicates dropped", func() {
+			stats := &generationStats{}
+			metrics := allMetrics(Options{roots: []string{"testdata/conflicts", "testdata/unmapped"}, stats: stats})
+			Expect(stats.PackagesParsed).To(Equal(3))
+			Expect(stats.FilesScanned).To(Equal(3))
+			Expect(stats.MetricsFound).To(Equal(len(metrics)))
+			Expect(stats.DedupeCollisions).To(Equal(1))
+			Expect(stats.UnresolvedExpressions).To(Equal(1))
+
+			out := &bytes.Buffer{}
+			Expect(stats.write(out, time.Now())).To(Succeed())
+			Expect(strings.Count(out.String(), "\n")).To(Equal(1))
+			Expect(out.String()).To(HavePrefix(`{"packagesParsed":3,"filesScanned":3,`))
+		})
+		It("should write the stats once when the run exits on a fatal error", func() {
+			DeferCleanup(func(hooks []func()) { exitHooks = hooks }, exitHooks)
+			out := &bytes.Buffer{}
+			write := (&generationStats{MetricsFound: 2}).writeOnExit(out, time.Now())
+			for _, hook := range exitHooks {
+				hook()
+			}
+			write()
+			Expect(strings.Count(out.String(), "\n")).To(Equal(1))
+			Expect(out.String()).To(ContainSubstring(`"metricsFound":2,`))
+		})
+	})
+	Context("Roots", func() {
+		It("should expand glob patterns into the paths they match", func() {
+			Expect(expandRoots([]string{"testdata/subsystem*", "path/to/literal"})).To(Equal([]string{"testdata/subsystemfunc", "path/to/literal"}))
+		})
+		It("should extract the metrics of a single file", func() {
+			metrics := declaredMetrics(Options{roots: []string{"testdata/controllers/nodepool/metrics.go"}})
+			Expect(lo.Map(metrics, func(m metricInfo, _ int) string { return m.qualifiedName() })).To(ConsistOf("karpenter_nodepools_ready"))
+			Expect(metrics[0].controller()).To(Equal("nodepool"))
+		})
+		It("should fail when a glob pattern doesn't match any paths", func() {
+			_, err := expandRoots([]string{"testdata/missing*"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("Cache", func() {
+		var dir, cacheDir string
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			cacheDir = GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "metrics.go"), lo.Must(os.ReadFile("testdata/funcs/metrics.go")), 0644)).To(Succeed())
+		})
+		It("should reuse the cached metrics for an unchanged package", func() {
+			Expect(declaredMetrics(Options{roots: []string{dir}, cacheDir: cacheDir})).To(HaveLen(2))
+			Expect(lo.Must(os.ReadDir(cacheDir))).To(HaveLen(1))
+
+			// Replace the cached metrics so that we can tell that the source wasn't parsed again
+			data := lo.Must(json.Marshal(cacheEntry{Key: lo.Must(cacheKey(dir, nil, "")), Metrics: []metricInfo{{Name: "cached", Help: "A cached metric."}}}))
+			Expect(os.WriteFile(filepath.Join(cacheDir, lo.Must(os.ReadDir(cacheDir))[0].Name()), data, 0644)).To(Succeed())
+			metrics := declaredMetrics(Options{roots: []string{dir}, cacheDir: cacheDir})
+			Expect(metrics).To(HaveLen(1))
+			Expect(metrics[0].Name).To(Equal("cached"))
+		})
+		It("should drop metrics that were removed from a changed package", func() {
+			Expect(declaredMetrics(Options{roots: []string{dir}, cacheDir: cacheDir})).To(HaveLen(2))
+
+			source := string(lo.Must(os.ReadFile(filepath.Join(dir, "metrics.go"))))
+			source = source[:strings.Index(source, "\tWorkersStarted")] + ")\n"
+			Expect(os.WriteFile(filepath.Join(dir, "metrics.go"), []byte(source), 0644)).To(Succeed())
+			Expect(os.Chtimes(filepath.Join(dir, "metrics.go"), time.Now().Add(time.Minute), time.Now().Add(time.Minute))).To(Succeed())
+			metrics := declaredMetrics(Options{roots: []string{dir}, cacheDir: cacheDir})
+			Expect(metrics).To(HaveLen(1))
+			Expect(metrics[0].qualifiedName()).To(Equal("karpenter_runtime_goroutines"))
+		})
+	})
+	Context("Only Changed", func() {
+		var dir string
+		git := func(args ...string) {
+			cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+			out, err := cmd.CombinedOutput()
+			Expect(err).ToNot(HaveOccurred(), string(out))
+		}
+		changedMetrics := func() []metricChange {
+			changes, _ := lo.Must2(getChangedMetrics(withDefaults(Options{roots: []string{dir}}), "HEAD"))
+			return changes
+		}
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			Expect(os.MkdirAll(filepath.Join(dir, "counters"), 0755)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(dir, "controllers"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "counters", "metrics.go"), lo.Must(os.ReadFile("testdata/counters/metrics.go")), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "controllers", "metrics.go"), lo.Must(os.ReadFile("testdata/controllers/nodepool/metrics.go")), 0644)).To(Succeed())
+			git("init", "-q")
+			git("add", "-A")
+			git("commit", "-q", "-m", "initial")
+		})
+		It("should report the metric-level changes in the packages with changed files", func() {
+			source := string(lo.Must(os.ReadFile(filepath.Join(dir, "counters", "metrics.go"))))
+			source = strings.Replace(source, "Number of gadgets created in total.", "Number of gadgets created.", 1)
+			source = source[:strings.Index(source, "\tWidgetsCount")] + source[strings.Index(source, "\tGadgetsCreated"):]
+			source = strings.Replace(source, `Name:      "deleted",`, `Name:      "destroyed_total",`, 1)
+			Expect(os.WriteFile(filepath.Join(dir, "counters", "metrics.go"), []byte(source), 0644)).To(Succeed())
+
+			changes := changedMetrics()
+			Expect(lo.Map(changes, func(c metricChange, _ int) string { return c.String() })).To(Equal([]string{
+				"changed karpenter_gadgets_created_total (help)",
+				"removed karpenter_widgets_count",
+				"removed karpenter_widgets_deleted",
+				"added karpenter_widgets_destroyed_total",
+			}))
+		})
+		It("should report the metrics of packages that aren't tracked yet", func() {
+			Expect(os.MkdirAll(filepath.Join(dir, "funcs"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "funcs", "metrics.go"), lo.Must(os.ReadFile("testdata/funcs/metrics.go")), 0644)).To(Succeed())
+			changes := changedMetrics()
+			Expect(changes).ToNot(BeEmpty())
+			Expect(lo.EveryBy(changes, func(c metricChange) bool { return c.kind == changeAdded })).To(BeTrue())
+		})
+		It("should report nothing without changes", func() {
+			Expect(changedMetrics()).To(BeEmpty())
+		})
+		It("should skip the metrics set by unsupported values with a warning when fatals are relaxed", func() {
+			Expect(os.MkdirAll(filepath.Join(dir, "unsupported"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "unsupported", "metrics.go"), []byte(`package unsupported
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var Dropped = prometheus.NewCounter(prometheus.CounterOpts{Namespace: "karpenter", Subsystem: "queue", Name: fmt.Sprintf("%s_total", "dropped"), Help: "Number of items dropped in total."})
+`), 0644)).To(Succeed())
+			changes, warnings := lo.Must2(getChangedMetrics(withDefaults(Options{roots: []string{dir}, relaxFatals: true}), "HEAD"))
+			Expect(changes).To(BeEmpty())
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0].rule).To(Equal("skipped"))
+		})
+	})
+	Context("Baselines", func() {
+		It("should parse back the names and stability levels of a rendered markdown document", func() {
+			opts := withDefaults(Options{roots: []string{"testdata/controllers", "testdata/libraries"}, config: lo.Must(loadConfig("testdata/config/deprecated.yaml"))})
+			documented := lo.Map(documentedMetrics(opts, getCheckedMetrics(opts)), func(m documentedMetric, _ int) documentedMetric {
+				return documentedMetric{Name: m.Name, StabilityLevel: m.StabilityLevel}
+			})
+			Expect(parseMarkdownBaseline([]byte(generateWithOptions(opts)))).To(ConsistOf(documented))
+			opts.groupBy = groupByNamespace
+			Expect(parseMarkdownBaseline([]byte(generateWithOptions(opts)))).To(ConsistOf(documented))
+		})
+		It("should report the changes since a markdown baseline", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "metrics.md")
+			Expect(os.WriteFile(path, []byte(generateWithOptions(Options{roots: []string{"testdata/controllers"}, config: lo.Must(loadConfig("testdata/config/deprecated.yaml"))})), 0644)).To(Succeed())
+			baseline := lo.Must(loadBaseline(path, baselineFormatMarkdown))
+			opts := withDefaults(Options{roots: []string{"testdata/controllers", "testdata/funcs"}})
+			changes := diffBaseline(baseline, documentedMetrics(opts, getCheckedMetrics(opts)))
+			Expect(changes).To(ContainElement(metricChange{kind: changeChanged, name: "karpenter_nodes_registered_total", fields: []string{"stability level"}}))
+			Expect(changes).To(ContainElement(metricChange{kind: changeAdded, name: "karpenter_runtime_goroutines"}))
+			Expect(lo.CountBy(changes, func(c metricChange) bool { return c.kind == changeRemoved })).To(BeZero())
+		})
+		It("should report the changes since a JSON baseline", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "metrics.json")
+			Expect(os.WriteFile(path, []byte(generateWithOptions(Options{roots: []string{"testdata/controllers", "testdata/funcs"}, format: formatJSON})), 0644)).To(Succeed())
+			baseline := lo.Must(loadBaseline(path, baselineFormatJSON))
+			opts := withDefaults(Options{roots: []string{"testdata/controllers"}})
+			changes := diffBaseline(baseline, documentedMetrics(opts, getCheckedMetrics(opts)))
+			Expect(changes).ToNot(BeEmpty())
+			Expect(lo.EveryBy(changes, func(c metricChange) bool { return c.kind == changeRemoved })).To(BeTrue())
+			Expect(changes).To(ContainElement(metricChange{kind: changeRemoved, name: "karpenter_runtime_goroutines"}))
+		})
+	})
+	Context("Commit Footer", func() {
+		It("should only render the commit footer when a commit is provided", func() {
+			Expect(generate("testdata/subsystemfunc")).ToNot(ContainSubstring("<!-- generated from"))
+			Expect(generateWithOptions(Options{roots: []string{"testdata/subsystemfunc"}, commit: "abc123"})).To(HaveSuffix("<!-- generated from abc123 -->\n"))
+		})
+		It("should ignore the commit footer when checking for changes", func() {
+			existing := generateWithOptions(Options{roots: []string{"testdata/subsystemfunc"}, commit: "abc123"})
+			generated := generateWithOptions(Options{roots: []string{"testdata/subsystemfunc"}, commit: "def456"})
+			Expect(upToDate([]byte(existing), []byte(generated), true)).To(BeTrue())
+			Expect(upToDate([]byte(existing), []byte(generated), false)).To(BeFalse())
+		})
+	})
+})
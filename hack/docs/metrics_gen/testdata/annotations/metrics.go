@@ -0,0 +1,81 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+// Utilization is the fraction of the cluster's capacity that is requested by pods
+//
+//metric:range=0..1
+var Utilization = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "cluster",
+		Name:      "utilization_ratio",
+		Help:      "Fraction of the cluster's capacity that is requested by pods.",
+	},
+)
+
+var (
+	//metric:range=-1..1
+	Skew = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "cluster",
+			Name:      "zone_skew",
+			Help:      "Skew of pods across zones.",
+		},
+	)
+	//metric:range=1..0
+	Backwards = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "cluster",
+			Name:      "backwards",
+			Help:      "A gauge with a malformed range.",
+		},
+	)
+	//metric:internal
+	ReconcileCacheMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "cluster",
+			Name:      "reconcile_cache_misses_total",
+			Help:      "Number of reconcile cache misses in total, for diagnosing the cache.",
+		},
+	)
+	//metric:staleness=30s
+	PendingPods = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "cluster",
+			Name:      "pending_pods",
+			Help:      "Number of pods that are pending, sampled periodically.",
+		},
+	)
+	//metric:exemplars
+	ReconcileDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "cluster",
+			Name:      "reconcile_duration_seconds",
+			Help:      "Duration of reconciles in seconds.",
+		},
+	)
+)
@@ -0,0 +1,40 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"example.com/vendor/telemetry"
+)
+
+var (
+	Syncs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: telemetry.Namespace,
+			Subsystem: "sync",
+			Name:      "runs_total",
+			Help:      "Number of syncs in total.",
+		},
+	)
+	SyncErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: telemetry.Namespace,
+			Subsystem: "sync",
+			Name:      telemetry.ErrorsName,
+			Help:      "Number of sync errors in total.",
+		},
+	)
+)
@@ -0,0 +1,52 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckets
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var (
+	ReconcileDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "buckets",
+			Name:      "reconcile_duration_seconds",
+			Help:      "Duration of reconciles in seconds.",
+			Buckets:   latencyBuckets,
+		},
+	)
+	RequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "buckets",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of requests in seconds.",
+			Buckets:   latencyBuckets,
+		},
+		[]string{"operation"},
+	)
+	BatchSize = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "buckets",
+			Name:      "batch_size",
+			Help:      "Size of batches.",
+			Buckets:   prometheus.LinearBuckets(1, 10, 5),
+		},
+	)
+)
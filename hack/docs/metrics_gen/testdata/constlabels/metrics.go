@@ -0,0 +1,61 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package constlabels
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const (
+	priority = "high"
+	// RetriesKey shadows the mapping of the same name
+	RetriesKey = "attempt"
+)
+
+var baseLabels = prometheus.Labels{"component": "karpenter"}
+
+var (
+	BatchDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace:   metrics.Namespace,
+			Subsystem:   "batcher",
+			Name:        "batch_duration_seconds",
+			Help:        "Duration of batches in seconds.",
+			ConstLabels: prometheus.Labels{"queue": "provisioning"},
+		},
+	)
+	BatchSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   metrics.Namespace,
+			Subsystem:   "batcher",
+			Name:        "batch_size",
+			Help:        "Size of the last batch.",
+			ConstLabels: lo.Assign(baseLabels, prometheus.Labels{"queue": "disruption", "priority": priority}),
+		},
+	)
+	BatchRetries = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   metrics.Namespace,
+			Subsystem:   "batcher",
+			Name:        "batch_retries",
+			Help:        "Number of retries of the last batch.",
+			ConstLabels: prometheus.Labels{"counter": RetriesKey},
+		},
+		[]string{RetriesKey},
+	)
+)
@@ -0,0 +1,42 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package curried
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var (
+	ReceivedMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "interruption",
+			Name:      "received_messages_total",
+			Help:      "Number of interruption messages received in total.",
+		},
+		[]string{"queue", "message_type"},
+	).MustCurryWith(prometheus.Labels{"queue": "default"})
+	DeletedMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "interruption",
+			Name:      "deleted_messages_total",
+			Help:      "Number of interruption messages deleted in total.",
+		},
+		[]string{"queue"},
+	).With(prometheus.Labels{"queue": "default"})
+)
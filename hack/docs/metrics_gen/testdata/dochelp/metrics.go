@@ -0,0 +1,45 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dochelp
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var (
+	// Number of pods that were preempted to make room for pods of a higher priority,
+	// labeled by the priority class of the preempting pod.
+	//metric:typical-rate=low
+	PodsPreempted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "pods",
+			Name:      "preempted_total",
+			Help:      "",
+		},
+		[]string{"priority_class"},
+	)
+	// This comment is superseded by the help of the declaration.
+	PodsBound = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "pods",
+			Name:      "bound_total",
+			Help:      "Number of pods bound to nodes in total.",
+		},
+	)
+)
@@ -0,0 +1,42 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package featuregates
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var (
+	//metric:feature-gate=SpotToSpotConsolidation
+	SpotToSpotReplacements = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "consolidation",
+			Name:      "spot_to_spot_replacements_total",
+			Help:      "Number of spot nodes replaced by cheaper spot nodes in total.",
+		},
+	)
+	//metric:feature-gate=SpotToSpotConsolidaton
+	SpotToSpotCandidates = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "consolidation",
+			Name:      "spot_to_spot_candidates",
+			Help:      "Number of spot nodes that are candidates for replacement by cheaper spot nodes.",
+		},
+	)
+)
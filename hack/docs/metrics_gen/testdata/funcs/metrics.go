@@ -0,0 +1,48 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package funcs
+
+import (
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var startedWorkers float64
+
+var (
+	Goroutines = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "runtime",
+			Name:      "goroutines",
+			Help:      "Number of goroutines that currently exist.",
+		},
+		func() float64 {
+			return float64(runtime.NumGoroutine())
+		},
+	)
+	WorkersStarted = prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "runtime",
+			Name:      "workers_started_total",
+			Help:      "Number of workers started in total.",
+		},
+		func() float64 { return startedWorkers },
+	)
+)
@@ -0,0 +1,45 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ignoredfields
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var (
+	SchedulingDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Buckets:                        append(prometheus.ExponentialBuckets(0.01, 2, 10), 30, 60),
+			NativeHistogramBucketFactor:    1.1,
+			NativeHistogramMaxBucketNumber: uint32(len(prometheus.DefBuckets) * 10),
+			NativeHistogramMinResetDuration: func() time.Duration {
+				return time.Hour
+			}(),
+			Help:      "Duration of scheduling simulations in seconds.",
+			Name:      "simulation_duration_seconds",
+			Subsystem: "scheduler",
+			Namespace: metrics.Namespace,
+			ConstLabels: lo.Assign(prometheus.Labels{"component": "scheduler"}, map[string]string{
+				"version": metrics.Version(),
+			}),
+		},
+		[]string{"nodepool"},
+	)
+)
@@ -0,0 +1,47 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lazyinit
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var (
+	SnapshotsTaken = sync.OnceValue(func() prometheus.Counter {
+		return prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: metrics.Namespace,
+				Subsystem: "cluster",
+				Name:      "snapshots_taken_total",
+				Help:      "Number of snapshots of the cluster taken in total.",
+			},
+		)
+	})
+	SnapshotSize = sync.OnceValue(func() *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: metrics.Namespace,
+				Subsystem: "cluster",
+				Name:      "snapshot_size",
+				Help:      "Number of objects in the most recent snapshot of the cluster.",
+			},
+			[]string{"kind"},
+		)
+	})
+)
@@ -0,0 +1,42 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package longhelp
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var (
+	DecisionDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "disruption",
+			Name:      "decision_duration_seconds",
+			Help: "Duration of disruption decisions in seconds. A decision spans simulating the scheduling of the pods on " +
+				"the candidate nodes, validating that the candidates are still disruptable after the validation period, " +
+				"and launching any replacement nodes.",
+		},
+	)
+	Candidates = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "disruption",
+			Name:      "candidates",
+			Help:      "Number of disruption candidates.",
+		},
+	)
+)
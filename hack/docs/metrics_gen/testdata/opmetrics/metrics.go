@@ -0,0 +1,69 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opmetrics
+
+import (
+	opmetrics "github.com/awslabs/operatorpkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const leaseSubsystem = "lease"
+
+var (
+	QueueDepth = opmetrics.NewPrometheusGauge(
+		crmetrics.Registry,
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: queueSubsystem,
+			Name:      "depth",
+			Help:      "Number of items in the queue.",
+		},
+		[]string{"queue"},
+	)
+	BatchesFlushed = opmetrics.NewPrometheusCounter(
+		crmetrics.Registry,
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: batcherSubsystem,
+			Name:      "batches_flushed_total",
+			Help:      "Number of batches flushed in total.",
+		},
+		[]string{"batcher"},
+	)
+	RegistryLatency = opmetrics.NewPrometheusHistogram(
+		crmetrics.Registry,
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: registrySubsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of registry requests in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"operation"},
+	)
+	LeaseRenewal = opmetrics.NewPrometheusSummary(
+		crmetrics.Registry,
+		prometheus.SummaryOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: leaseSubsystem,
+			Name:      "renewal_duration_seconds",
+			Help:      "Duration of lease renewals in seconds.",
+		},
+		[]string{},
+	)
+)
@@ -0,0 +1,44 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promauto
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	CacheHits = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "cache",
+			Name:      "hits_total",
+			Help:      "Number of cache hits in total.",
+		},
+	)
+	CacheEntries = promauto.With(registry).NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "cache",
+			Name:      "entries",
+			Help:      "Number of entries in the cache.",
+		},
+		[]string{"kind"},
+	)
+)
@@ -0,0 +1,41 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rawstrings
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var GarbageCollected = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "nodeclaims",
+		Name:      `garbage_collected_total`,
+		Help:      `Number of nodeclaims garbage collected in total after their instance was "orphaned"`,
+	},
+)
+
+// The labels are quoted in either style, and an interpreted label with an escape is decoded
+var Disrupted = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "nodeclaims",
+		Name:      "disrupted_total",
+		Help:      "Number of nodeclaims disrupted in total.",
+	},
+	[]string{`nodepool`, "capacity\x5ftype"},
+)
@@ -0,0 +1,70 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registration
+
+import (
+	opmetrics "github.com/awslabs/operatorpkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var (
+	QueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "queue",
+			Name:      "depth",
+			Help:      "Number of items in the queue.",
+		},
+	)
+	// QueueDropped is constructed but never registered, so it isn't exposed
+	QueueDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "queue",
+			Name:      "dropped_total",
+			Help:      "Number of items dropped from the queue in total.",
+		},
+	)
+	QueueProcessed = opmetrics.NewPrometheusCounter(
+		crmetrics.Registry,
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "queue",
+			Name:      "processed_total",
+			Help:      "Number of items processed from the queue in total.",
+		},
+		[]string{},
+	)
+	Collectors = map[string]prometheus.Collector{
+		"retries": prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: metrics.Namespace,
+				Subsystem: "queue",
+				Name:      "retries_total",
+				Help:      "Number of items retried in total.",
+			},
+		),
+	}
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(QueueDepth)
+	for _, c := range Collectors {
+		crmetrics.Registry.MustRegister(c)
+	}
+}
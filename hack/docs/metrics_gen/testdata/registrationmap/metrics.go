@@ -0,0 +1,49 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registrationmap
+
+import (
+	opmetrics "github.com/awslabs/operatorpkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+var Collectors = map[string]prometheus.Collector{
+	"queued": prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "batcher",
+			Name:      "queued_items",
+			Help:      "Number of items waiting to be batched.",
+		},
+	),
+	"flushed": opmetrics.NewPrometheusCounter(
+		crmetrics.Registry,
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "batcher",
+			Name:      "flushes_total",
+			Help:      "Number of batches flushed in total.",
+		},
+		[]string{"reason"},
+	),
+}
+
+// Buckets isn't a registration map and its values aren't metrics
+var Buckets = map[string][]float64{
+	"fast": prometheus.LinearBuckets(0, 1, 10),
+}
@@ -0,0 +1,31 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statuscondition
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	NodeClaimConditionCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "operator",
+			Subsystem: "nodeclaim_status_condition",
+			Name:      "count",
+			Help:      "The number of conditions for a nodeclaim, declared statically.",
+		},
+		[]string{"type", "status"},
+	)
+)
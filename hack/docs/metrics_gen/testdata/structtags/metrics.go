@@ -0,0 +1,26 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package structtags
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ProvisionerMetrics are registered by reflecting over the tags of its fields
+type ProvisionerMetrics struct {
+	Scheduled prometheus.Counter `metric:"namespace=karpenter,subsystem=provisioner,name=pods_scheduled_total,type=counter,help=Number of pods scheduled in total, by nodepool.,labels=nodepool zone"`
+	Pending   prometheus.Gauge   `metric:"namespace=karpenter,subsystem=provisioner,name=pods_pending,type=gauge,help=Number of pods that are pending."`
+	name      string             `json:"name"`
+}
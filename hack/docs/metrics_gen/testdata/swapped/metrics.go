@@ -0,0 +1,43 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package swapped
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const nodeSubsystem = "nodes"
+
+var (
+	// NodesDrifted declares its subsystem in the Namespace field
+	NodesDrifted = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: nodeSubsystem,
+			Subsystem: metrics.Namespace,
+			Name:      "drifted_total",
+			Help:      "Number of nodes found to have drifted in total.",
+		},
+	)
+	NodesExpired = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: nodeSubsystem,
+			Name:      "expired_total",
+			Help:      "Number of nodes that expired in total.",
+		},
+	)
+)
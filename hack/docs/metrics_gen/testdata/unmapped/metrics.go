@@ -0,0 +1,42 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package unmapped
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"example.com/vendor/telemetry"
+)
+
+const reconcilerSubsystem = "reconciler"
+
+var (
+	Reconciles = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: telemetry.Namespace,
+			Subsystem: reconcilerSubsystem,
+			Name:      "reconciles_total",
+			Help:      "Number of reconciles in total.",
+		},
+	)
+	Errors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "karpenter",
+			Subsystem: reconcilerSubsystem,
+			Name:      "errors_total",
+			Help:      "Number of reconcile errors in total.",
+		},
+	)
+)
@@ -0,0 +1,27 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wrappers
+
+import (
+	"github.com/aws/karpenter-provider-aws/hack/docs/metrics_gen/testdata/wrappers/requestpb"
+)
+
+const subsystem = "interruption"
+
+var (
+	RequestsHandled = requestpb.NewRequestCounter("requests_handled_total", "Number of requests handled in total.", []string{"method", "code"})
+	RequestDuration = requestpb.NewRequestDuration(subsystem, "request_duration_seconds", "Duration of requests in seconds.")
+	RequestsDropped = requestpb.NewRequestCounter(droppedName, "Number of requests dropped in total.", []string{"method"})
+)
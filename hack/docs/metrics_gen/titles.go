@@ -0,0 +1,84 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/awslabs/operatorpkg/serrors"
+	"github.com/samber/lo"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	sectionMetrics         = "metrics"
+	sectionGeneral         = "general"
+	sectionLibrary         = "library"
+	sectionRelationships   = "relationships"
+	sectionStabilityLevels = "stability-levels"
+	sectionDeprecated      = "deprecated"
+	sectionCheatSheet      = "cheatsheet"
+)
+
+// sectionTitles are the English titles of the sections of the document that aren't the metrics of a subsystem
+var sectionTitles = map[string]string{
+	sectionMetrics:         "Metrics",
+	sectionGeneral:         "General Metrics",
+	sectionLibrary:         "Library Metrics",
+	sectionRelationships:   "Metric Relationships",
+	sectionStabilityLevels: "Stability Levels",
+	sectionDeprecated:      "Deprecated Metrics",
+	sectionCheatSheet:      "Metrics Cheat Sheet",
+}
+
+// titles translate the structural titles of the document
+type titles struct {
+	Subsystems map[string]string `json:"subsystems,omitempty"`
+	Sections   map[string]string `json:"sections,omitempty"`
+}
+
+// loadTitles reads the titles at path
+func loadTitles(path string) (titles, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return titles{}, serrors.Wrap(fmt.Errorf("reading titles, %w", err), "path", path)
+	}
+	t := titles{}
+	if err := yaml.UnmarshalStrict(data, &t); err != nil {
+		return titles{}, serrors.Wrap(fmt.Errorf("parsing titles, %w", err), "path", path)
+	}
+	for _, key := range slices.Sorted(maps.Keys(t.Sections)) {
+		if _, ok := sectionTitles[key]; !ok {
+			return titles{}, serrors.Wrap(fmt.Errorf("section must be one of %v", slices.Sorted(maps.Keys(sectionTitles))), "path", path, "section", key)
+		}
+	}
+	return t, nil
+}
+
+// subsystem returns the heading of the metrics of a subsystem
+func (t titles) subsystem(subsystem string) string {
+	if title, ok := t.Subsystems[subsystem]; ok {
+		return title
+	}
+	return fmt.Sprintf("%s Metrics", subsystemTitle(subsystem))
+}
+
+// section returns the title of a section of the document that isn't the metrics of a subsystem
+func (t titles) section(key string) string {
+	return lo.CoalesceOrEmpty(t.Sections[key], sectionTitles[key])
+}